@@ -0,0 +1,205 @@
+// Content-addressable store on top of weedo, identical content is uploaded
+// once and shared by reference counting.
+//
+//	store := cas.New(client, cas.NewMemoryIndex())
+//	o, err := store.Put("avatar.png", "image/png", r) // o.Hash addresses the content
+//	body, info, err := store.Get(o.Hash)
+//	err = store.Delete(o.Hash) // the fid is deleted with the last reference
+package cas
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/Archs/weedo"
+)
+
+// Content of the hash is not in the store
+var ErrNotFound = errors.New("cas: not found")
+
+// content larger than it is buffered in a temp file while hashing
+const maxMemory = 8 << 20
+
+type Store struct {
+	client *weedo.Client
+	index  Index
+
+	mu    sync.Mutex
+	locks map[string]*hashLock
+}
+
+// lock of a hash being put or deleted
+type hashLock struct {
+	sync.Mutex
+	waiters int
+}
+
+func New(client *weedo.Client, index Index) *Store {
+	return &Store{
+		client: client,
+		index:  index,
+		locks:  make(map[string]*hashLock),
+	}
+}
+
+func (s *Store) lock(hash string) {
+	s.mu.Lock()
+	l, ok := s.locks[hash]
+	if !ok {
+		l = new(hashLock)
+		s.locks[hash] = l
+	}
+	l.waiters++
+	s.mu.Unlock()
+	l.Lock()
+}
+
+func (s *Store) unlock(hash string) {
+	s.mu.Lock()
+	l := s.locks[hash]
+	l.waiters--
+	if l.waiters == 0 {
+		delete(s.locks, hash)
+	}
+	s.mu.Unlock()
+	l.Unlock()
+}
+
+// Put content of r, which is uploaded by Client.AssignUpload unless the
+// same content is in the store. Either way the object gets one more reference
+func (s *Store) Put(filename, mimeType string, r io.Reader) (*Object, error) {
+	content, hash, size, err := buffer(r)
+	if err != nil {
+		return nil, err
+	}
+	defer content.Close()
+
+	s.lock(hash)
+	defer s.unlock(hash)
+	o, err := s.index.Get(hash)
+	if err != nil {
+		return nil, err
+	}
+	if o == nil {
+		fid, _, err := s.client.AssignUpload(filename, mimeType, content)
+		if err != nil {
+			return nil, err
+		}
+		o = &Object{Hash: hash, Fid: fid, Size: size}
+	}
+	o.Refs++
+	if err = s.index.Put(o); err != nil {
+		if o.Refs == 1 {
+			// not referenced by the index, don't leave it behind
+			s.deleteFid(o.Fid)
+		}
+		return nil, err
+	}
+	return o, nil
+}
+
+// Object of hash, ErrNotFound if it's not in the store
+func (s *Store) Stat(hash string) (*Object, error) {
+	o, err := s.index.Get(hash)
+	if err == nil && o == nil {
+		err = ErrNotFound
+	}
+	return o, err
+}
+
+// Download content of hash, the caller must close the returned body
+func (s *Store) Get(hash string) (io.ReadCloser, *weedo.FileInfo, error) {
+	o, err := s.Stat(hash)
+	if err != nil {
+		return nil, nil, err
+	}
+	return s.client.Get(o.Fid)
+}
+
+// Drop a reference of hash, the fid is deleted by Client.Delete with the
+// last reference
+func (s *Store) Delete(hash string) error {
+	s.lock(hash)
+	defer s.unlock(hash)
+	o, err := s.Stat(hash)
+	if err != nil {
+		return err
+	}
+	if o.Refs > 1 {
+		o.Refs--
+		return s.index.Put(o)
+	}
+	// the object is kept in the index unless the fid is deleted
+	if err = s.deleteFid(o.Fid); err != nil {
+		return err
+	}
+	return s.index.Delete(hash)
+}
+
+// delete fid, which is deleted already if it's not found
+func (s *Store) deleteFid(fid string) error {
+	ret := s.client.DeleteMany([]string{fid})[0]
+	if ret.Error != "" && ret.Status != http.StatusNotFound {
+		return errors.New("cas: deleting " + fid + ": " + ret.Error)
+	}
+	return nil
+}
+
+// Hash of content in hex, the address of it in the store
+func Hash(r io.Reader) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// buffered content to upload after hashing
+type buffered struct {
+	io.Reader
+	file *os.File
+}
+
+func (b *buffered) Close() error {
+	if b.file == nil {
+		return nil
+	}
+	b.file.Close()
+	return os.Remove(b.file.Name())
+}
+
+// read r into memory, or a temp file if it's larger than maxMemory, and hash it
+func buffer(r io.Reader) (content *buffered, hash string, size int64, err error) {
+	h := sha256.New()
+	r = io.TeeReader(r, h)
+	mem := new(bytes.Buffer)
+	size, err = io.CopyN(mem, r, maxMemory+1)
+	if err == io.EOF {
+		return &buffered{Reader: mem}, hex.EncodeToString(h.Sum(nil)), size, nil
+	}
+	if err != nil {
+		return
+	}
+	file, err := ioutil.TempFile("", "weedo-cas")
+	if err != nil {
+		return
+	}
+	content = &buffered{file: file}
+	n, err := io.Copy(file, io.MultiReader(mem, r))
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		content.Close()
+		return nil, "", 0, err
+	}
+	content.Reader = file
+	return content, hex.EncodeToString(h.Sum(nil)), n, nil
+}
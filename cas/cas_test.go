@@ -0,0 +1,186 @@
+package cas
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Archs/weedo"
+	"github.com/Archs/weedo/internal/fakeweed"
+)
+
+func testStore(t *testing.T, index Index) {
+	s := fakeweed.New(nil)
+	defer s.Close()
+	store := New(weedo.NewClient(s.URL), index)
+
+	a, err := store.Put("a.png", "image/png", strings.NewReader("avatar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := store.Put("b.png", "image/png", strings.NewReader("avatar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Uploads) != 1 || a.Fid != b.Fid || a.Hash != b.Hash || b.Refs != 2 || b.Size != 6 {
+		t.Fatalf("same content should be uploaded once: %d %+v %+v", len(s.Uploads), a, b)
+	}
+	if hash, _ := Hash(strings.NewReader("avatar")); hash != a.Hash {
+		t.Error("hash:", hash, a.Hash)
+	}
+	c, err := store.Put("c.png", "image/png", strings.NewReader("other"))
+	if err != nil || c.Fid == a.Fid || len(s.Uploads) != 2 {
+		t.Fatal("new content should be uploaded:", err, c)
+	}
+
+	body, _, err := store.Get(a.Hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := ioutil.ReadAll(body)
+	body.Close()
+	if string(data) != "avatar" {
+		t.Error("get:", string(data))
+	}
+
+	if err = store.Delete(a.Hash); err != nil || s.Deletes != 0 {
+		t.Fatal("referenced content deleted:", err, s.Deletes)
+	}
+	if o, err := store.Stat(a.Hash); err != nil || o.Refs != 1 {
+		t.Error("refs after delete:", o, err)
+	}
+	s.FailDelete = true
+	if err = store.Delete(a.Hash); err == nil {
+		t.Fatal("failed delete should be reported")
+	}
+	if o, err := store.Stat(a.Hash); err != nil || o.Refs != 1 {
+		t.Fatal("object of failed delete should be kept:", o, err)
+	}
+	s.FailDelete = false
+	if err = store.Delete(a.Hash); err != nil || s.Deletes != 1 || s.Files[a.Fid] != "" {
+		t.Fatal("last reference should delete the fid:", err, s.Deletes)
+	}
+	if _, err = store.Stat(a.Hash); err != ErrNotFound {
+		t.Error("deleted content should not be found:", err)
+	}
+	if err = store.Delete(a.Hash); err != ErrNotFound {
+		t.Error("delete twice:", err)
+	}
+}
+
+// index failing to put objects
+type failingIndex struct{ Index }
+
+func (failingIndex) Put(o *Object) error {
+	return errors.New("failed on purpose")
+}
+
+func TestPutIndexError(t *testing.T) {
+	s := fakeweed.New(nil)
+	defer s.Close()
+	store := New(weedo.NewClient(s.URL), failingIndex{NewMemoryIndex()})
+	if _, err := store.Put("a.png", "image/png", strings.NewReader("avatar")); err == nil {
+		t.Fatal("index error should be returned")
+	}
+	if len(s.Uploads) != 1 || len(s.Files) != 0 {
+		t.Error("uploaded fid should be deleted:", len(s.Uploads), s.Files)
+	}
+}
+
+func TestMemoryIndex(t *testing.T) {
+	testStore(t, NewMemoryIndex())
+}
+
+func TestFileIndex(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cas")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "index.jsonl")
+	idx, err := OpenFileIndex(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStore(t, idx)
+	idx.Close()
+
+	// reopen, with a broken last line
+	f, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	f.WriteString(`{"hash":"broken`)
+	f.Close()
+	if idx, err = OpenFileIndex(path); err != nil {
+		t.Fatal(err)
+	}
+	if idx.Len() != 1 {
+		t.Fatal("objects after reopen:", idx.Len())
+	}
+	hash, _ := Hash(strings.NewReader("other"))
+	if o, _ := idx.Get(hash); o == nil || o.Refs != 1 {
+		t.Error("object after reopen:", o)
+	}
+	if err = idx.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	idx.Put(&Object{Hash: "x", Fid: "3,01", Refs: 1})
+	idx.Close()
+	b, _ := ioutil.ReadFile(path)
+	if n := strings.Count(string(b), "\n"); n != 2 {
+		t.Errorf("lines after compact: %d\n%s", n, b)
+	}
+	if idx, err = OpenFileIndex(path); err != nil || idx.Len() != 2 {
+		t.Fatal("objects after compact:", err)
+	}
+	idx.Close()
+}
+
+func TestConcurrentPut(t *testing.T) {
+	s := fakeweed.New(nil)
+	defer s.Close()
+	store := New(weedo.NewClient(s.URL), NewMemoryIndex())
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, err := store.Put("f", "", strings.NewReader(fmt.Sprint(i%2))); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if len(s.Uploads) != 2 {
+		t.Error("uploads of 2 contents:", len(s.Uploads))
+	}
+	hash, _ := Hash(strings.NewReader("0"))
+	if o, _ := store.Stat(hash); o.Refs != 10 {
+		t.Error("refs:", o.Refs)
+	}
+}
+
+func TestBuffer(t *testing.T) {
+	data := strings.Repeat("x", maxMemory+10)
+	content, hash, size, err := buffer(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content.file == nil || size != int64(len(data)) {
+		t.Error("large content should be buffered in a file", size)
+	}
+	if want, _ := Hash(strings.NewReader(data)); hash != want {
+		t.Error("hash of large content")
+	}
+	b, _ := ioutil.ReadAll(content)
+	content.Close()
+	if string(b) != data {
+		t.Error("buffered content not match")
+	}
+	if _, err := os.Stat(content.file.Name()); !os.IsNotExist(err) {
+		t.Error("temp file not removed")
+	}
+}
@@ -0,0 +1,203 @@
+package cas
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+)
+
+// Stored content
+type Object struct {
+	Hash string `json:"hash"` // sha256 of content in hex
+	Fid  string `json:"fid"`
+	Size int64  `json:"size"`
+	Refs int64  `json:"refs"` // number of Puts not deleted
+}
+
+// Index of objects by hash, implementations must be safe for concurrent use
+type Index interface {
+	// Get object of hash, nil if not found
+	Get(hash string) (*Object, error)
+	// Put object, replacing the one of the same hash
+	Put(o *Object) error
+	Delete(hash string) error
+}
+
+// In-memory index, lost when the process exits
+type MemoryIndex struct {
+	mu      sync.RWMutex
+	objects map[string]Object
+}
+
+func NewMemoryIndex() *MemoryIndex {
+	return &MemoryIndex{objects: make(map[string]Object)}
+}
+
+func (idx *MemoryIndex) Get(hash string) (*Object, error) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	o, ok := idx.objects[hash]
+	if !ok {
+		return nil, nil
+	}
+	return &o, nil
+}
+
+func (idx *MemoryIndex) Put(o *Object) error {
+	idx.mu.Lock()
+	idx.objects[o.Hash] = *o
+	idx.mu.Unlock()
+	return nil
+}
+
+func (idx *MemoryIndex) Delete(hash string) error {
+	idx.mu.Lock()
+	delete(idx.objects, hash)
+	idx.mu.Unlock()
+	return nil
+}
+
+func (idx *MemoryIndex) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.objects)
+}
+
+// line of FileIndex, an object or the deletion of a hash
+type record struct {
+	Object
+	Deleted bool `json:"deleted,omitempty"`
+}
+
+// Index kept in memory and persisted as JSON lines appended to a file,
+// one line for each change. Compact rewrites the file with live objects only
+type FileIndex struct {
+	mem  *MemoryIndex
+	mu   sync.Mutex // guards file
+	path string
+	file *os.File
+}
+
+// Open index at path, which is created if it doesn't exist
+func OpenFileIndex(path string) (*FileIndex, error) {
+	idx := &FileIndex{mem: NewMemoryIndex(), path: path}
+	if err := idx.load(); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	idx.file = file
+	return idx, nil
+}
+
+func (idx *FileIndex) load() error {
+	file, err := os.Open(idx.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var r record
+		// the last line may be broken by a crash
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil || r.Hash == "" {
+			continue
+		}
+		if r.Deleted {
+			idx.mem.Delete(r.Hash)
+		} else {
+			idx.mem.Put(&r.Object)
+		}
+	}
+	return scanner.Err()
+}
+
+func (idx *FileIndex) Get(hash string) (*Object, error) {
+	return idx.mem.Get(hash)
+}
+
+func (idx *FileIndex) Put(o *Object) error {
+	return idx.append(record{Object: *o}, func() { idx.mem.Put(o) })
+}
+
+func (idx *FileIndex) Delete(hash string) error {
+	return idx.append(record{Object: Object{Hash: hash}, Deleted: true}, func() { idx.mem.Delete(hash) })
+}
+
+func (idx *FileIndex) Len() int {
+	return idx.mem.Len()
+}
+
+// append r to the file and apply it in memory, together for Compact
+func (idx *FileIndex) append(r record, apply func()) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if idx.file == nil {
+		return errors.New("cas: index closed")
+	}
+	if _, err = idx.file.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	apply()
+	return nil
+}
+
+// Rewrite the file with live objects only
+func (idx *FileIndex) Compact() error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if idx.file == nil {
+		return errors.New("cas: index closed")
+	}
+	tmp, err := os.Create(idx.path + ".tmp")
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	idx.mem.mu.RLock()
+	for _, o := range idx.mem.objects {
+		if err = enc.Encode(record{Object: o}); err != nil {
+			break
+		}
+	}
+	idx.mem.mu.RUnlock()
+	if err == nil {
+		err = w.Flush()
+	}
+	if e := tmp.Close(); err == nil {
+		err = e
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), idx.path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	idx.file.Close()
+	idx.file, err = os.OpenFile(idx.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	return err
+}
+
+func (idx *FileIndex) Close() error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if idx.file == nil {
+		return nil
+	}
+	err := idx.file.Close()
+	idx.file = nil
+	return err
+}
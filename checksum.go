@@ -0,0 +1,234 @@
+// integrity of files by content hashes
+package weedo
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Hashes of content in hex
+type Checksum struct {
+	MD5    string
+	SHA256 string
+}
+
+// Content of a file doesn't match the expected hash
+type ChecksumError struct {
+	Fid      string
+	Expected string
+	Actual   string
+}
+
+func (e *ChecksumError) Error() string {
+	return "checksum mismatch of " + e.Fid + ": expected " + e.Expected + ", actual " + e.Actual
+}
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// hashes of content written to it
+type hasher struct {
+	md5    hash.Hash
+	sha256 hash.Hash
+	crc    hash.Hash32
+	w      io.Writer
+	n      int64
+}
+
+func newHasher() *hasher {
+	h := &hasher{md5: md5.New(), sha256: sha256.New(), crc: crc32.New(crc32c)}
+	h.w = io.MultiWriter(h.md5, h.sha256, h.crc)
+	return h
+}
+
+func (h *hasher) Write(p []byte) (int, error) {
+	h.n += int64(len(p))
+	return h.w.Write(p)
+}
+
+func (h *hasher) sum() *Checksum {
+	return &Checksum{
+		MD5:    hex.EncodeToString(h.md5.Sum(nil)),
+		SHA256: hex.EncodeToString(h.sha256.Sum(nil)),
+	}
+}
+
+// compare etag of volume server, which is the md5 or, by older versions,
+// the crc32 (Castagnoli) of content in hex. Unknown etags are not checked
+func (h *hasher) checkETag(fid, etag string) error {
+	etag = strings.ToLower(strings.Trim(etag, `"`))
+	var actual string
+	switch len(etag) {
+	case 2 * md5.Size:
+		actual = hex.EncodeToString(h.md5.Sum(nil))
+	case 8:
+		actual = fmt.Sprintf("%08x", h.crc.Sum32())
+	default:
+		return nil
+	}
+	if actual != etag {
+		return &ChecksumError{Fid: fid, Expected: etag, Actual: actual}
+	}
+	return nil
+}
+
+// compare expected hash, md5 or sha256 in hex by length, optionally
+// prefixed by "md5:" or "sha256:"
+func (h *hasher) check(fid, expected string) error {
+	expected = strings.ToLower(expected)
+	if i := strings.Index(expected, ":"); i > 0 {
+		expected = expected[i+1:]
+	}
+	sum := h.sum()
+	actual := sum.SHA256
+	if len(expected) == 2*md5.Size {
+		actual = sum.MD5
+	}
+	if actual != expected {
+		return &ChecksumError{Fid: fid, Expected: expected, Actual: actual}
+	}
+	return nil
+}
+
+// body of a read verified against the etag once it's read to the end
+type verifyingReader struct {
+	io.ReadCloser
+	fid  string
+	etag string
+	h    *hasher
+}
+
+func (r *verifyingReader) Read(p []byte) (n int, err error) {
+	n, err = r.ReadCloser.Read(p)
+	r.h.Write(p[:n])
+	if err == io.EOF {
+		if e := r.h.checkETag(r.fid, r.etag); e != nil {
+			err = e
+		}
+	}
+	return
+}
+
+// verify body of resp by its etag, unless it's not the stored content, such
+// as chunked files, ranges and responses decompressed by the transport. A
+// compressed body must be verified before it's decompressed
+func verifyBody(fid string, resp *http.Response) io.ReadCloser {
+	etag := resp.Header.Get("Etag")
+	if etag == "" || resp.StatusCode != http.StatusOK || resp.Uncompressed ||
+		resp.Header.Get("X-File-Store") == "chunked" {
+		return resp.Body
+	}
+	return &verifyingReader{ReadCloser: resp.Body, fid: fid, etag: etag, h: newHasher()}
+}
+
+// Verify content of fid by expectedHash, md5 or sha256 in hex, optionally
+// prefixed by "md5:" or "sha256:". The etag of volume server is checked if
+// expectedHash is empty. Content not matching returns a *ChecksumError
+func (c *Client) Verify(fid, expectedHash string) error {
+	return c.verify(ScrubEntry{Fid: fid, Size: -1, Hash: expectedHash})
+}
+
+// read file of e to the end and check its size and hash
+func (c *Client) verify(e ScrubEntry) error {
+	vol, err := c.Volume(e.Fid, "")
+	if err != nil {
+		return err
+	}
+	jwt, err := c.jwt(e.Fid, false)
+	if err != nil {
+		return err
+	}
+	resp, err := vol.get(e.Fid, jwt, nil, true)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	h := newHasher()
+	if _, err = io.Copy(h, resp.Body); err != nil {
+		return err
+	}
+	if e.Size >= 0 && h.n != e.Size {
+		return &ChecksumError{Fid: e.Fid, Expected: fmt.Sprintf("size %d", e.Size), Actual: fmt.Sprintf("size %d", h.n)}
+	}
+	if e.Hash != "" {
+		return h.check(e.Fid, e.Hash)
+	}
+	return nil
+}
+
+// File to check by Scrub
+type ScrubEntry struct {
+	Fid  string
+	Size int64  // -1 if unknown
+	Hash string // expected hash for Verify, empty to check the etag only
+}
+
+// states of ScrubResult
+const (
+	ScrubOk      = "ok"
+	ScrubCorrupt = "corrupt" // size or hash mismatch
+	ScrubMissing = "missing"
+	ScrubError   = "error" // other errors, e.g. volume server unavailable
+)
+
+// Result of scrubbing a fid
+type ScrubResult struct {
+	Fid    string `json:"fid"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Re-read files of entries with parallel reads, reporting corrupt or
+// missing ones. Results are in the same order as entries
+func (c *Client) Scrub(entries []ScrubEntry, parallel int) []*ScrubResult {
+	if parallel < 1 {
+		parallel = 1
+	}
+	results := make([]*ScrubResult, len(entries))
+	idx := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range idx {
+				results[i] = c.scrub(entries[i])
+			}
+		}()
+	}
+	for i := range entries {
+		idx <- i
+	}
+	close(idx)
+	wg.Wait()
+	return results
+}
+
+func (c *Client) scrub(e ScrubEntry) *ScrubResult {
+	r := &ScrubResult{Fid: e.Fid, Status: ScrubOk}
+	err := c.verify(e)
+	switch err.(type) {
+	case nil:
+	case *ChecksumError:
+		r.Status = ScrubCorrupt
+	case *HttpError:
+		r.Status = ScrubError
+		if IsNotFound(err) {
+			r.Status = ScrubMissing
+		}
+	default:
+		r.Status = ScrubError
+	}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	return r
+}
@@ -0,0 +1,73 @@
+// chunked files
+package weedo
+
+import (
+	"bytes"
+	"io"
+)
+
+// Chunk of a chunked file
+type ChunkInfo struct {
+	Fid    string `json:"fid"`
+	Offset int64  `json:"offset"`
+	Size   int64  `json:"size"`
+}
+
+// Chunk manifest of seaweedfs, uploaded with ?cm=true in place of the
+// content of a large file. Volume servers read and delete the chunks
+// together with it
+type ChunkManifest struct {
+	Name   string       `json:"name,omitempty"`
+	Mime   string       `json:"mime,omitempty"`
+	Size   int64        `json:"size,omitempty"`
+	Chunks []*ChunkInfo `json:"chunks,omitempty"`
+}
+
+// Upload r in chunks of opt.MaxChunkSize. If r fits in one chunk nothing
+// is uploaded and cm is nil, rest is the content of r then
+func (c *Client) uploadChunks(filename, mimeType string, r io.Reader, opt *UploadOption) (cm *ChunkManifest, rest io.Reader, err error) {
+	max := opt.MaxChunkSize
+	buf := new(bytes.Buffer)
+	// one more byte to tell if there is a second chunk
+	n, err := io.CopyN(buf, r, max+1)
+	if err == io.EOF || err == nil && n <= max {
+		return nil, buf, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	r = io.MultiReader(bytes.NewReader(buf.Bytes()[max:]), r)
+	buf.Truncate(int(max))
+
+	chunkOpt := *opt
+	chunkOpt.MaxChunkSize = 0
+	chunkOpt.Compression = nil
+	cm = &ChunkManifest{Name: filename, Mime: mimeType}
+	for buf.Len() > 0 {
+		size := int64(buf.Len())
+		fid, _, err := c.AssignUploadWithOption(filename, "application/octet-stream", buf, &chunkOpt)
+		if err != nil {
+			c.deleteChunks(cm)
+			return nil, nil, err
+		}
+		cm.Chunks = append(cm.Chunks, &ChunkInfo{Fid: fid, Offset: cm.Size, Size: size})
+		cm.Size += size
+
+		buf.Reset()
+		if _, err = io.CopyN(buf, r, max); err != nil && err != io.EOF {
+			c.deleteChunks(cm)
+			return nil, nil, err
+		}
+	}
+
+	return cm, nil, nil
+}
+
+// best-effort cleanup of uploaded chunks
+func (c *Client) deleteChunks(cm *ChunkManifest) {
+	fids := make([]string, len(cm.Chunks))
+	for i, chunk := range cm.Chunks {
+		fids[i] = chunk.Fid
+	}
+	c.DeleteMany(fids)
+}
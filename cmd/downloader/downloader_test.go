@@ -0,0 +1,225 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Archs/weedo/internal/fakeweed"
+)
+
+func sum(s string) string {
+	h := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(h[:])
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "downloader")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// set flags and download into out
+func export(t *testing.T, s *fakeweed.Server, set func()) *stats {
+	server, filerUrl, src, manifestPath, outDir = s.URL, "", "", "", ""
+	concurrency, overwrite = 2, false
+	set()
+	if err := setup(); err != nil {
+		t.Fatal(err)
+	}
+	return run()
+}
+
+func writeFile(t *testing.T, path, content string) {
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func checkFiles(t *testing.T, dir string, want map[string]string) {
+	for name, content := range want {
+		b, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil || string(b) != content {
+			t.Errorf("%s: %q %v, want %q", name, b, err, content)
+		}
+	}
+}
+
+func TestManifest(t *testing.T) {
+	s := fakeweed.New(map[string]string{"3,0112345678": "hello", "3,0212345678": "world", "3,0312345678": "corrupt", "3,0412345678": "named"})
+	s.Names["3,0412345678"] = "report.pdf"
+	defer s.Close()
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	manifest := filepath.Join(dir, "files.jsonl")
+	out := filepath.Join(dir, "out")
+	writeFile(t, manifest, strings.Join([]string{
+		`{"path":"a/hello.txt","fid":"3,0112345678","size":5,"sha256":"` + sum("hello") + `"}`,
+		`{"path":"b.txt","fid":"3,0512345678","error":"failed"}`,
+		`{"path":"../../world.txt","fid":"3,0212345678","size":5}`,
+		`{"path":"c.txt","fid":"3,0312345678","size":7,"sha256":"` + sum("expected") + `"}`,
+		`{"broken`,
+		`{"fid":"3,0412345678"}`,
+		`{"path":"a/hello.txt","fid":"3,0112345678","size":5,"sha256":"` + sum("hello") + `"}`,
+	}, "\n"))
+
+	st := export(t, s, func() { manifestPath, outDir = manifest, out })
+	if st.files != 3 || len(st.failed) != 1 || st.failed[0].item.src != "3,0312345678" {
+		t.Fatal(st.Summary())
+	}
+	checkFiles(t, out, map[string]string{"a/hello.txt": "hello", "world.txt": "world", "report.pdf": "named"})
+	if _, err := os.Stat(filepath.Join(out, "c.txt.part")); !os.IsNotExist(err) {
+		t.Error("part file of corrupt download is kept")
+	}
+
+	// resume
+	gets := s.Gets
+	st = export(t, s, func() { manifestPath, outDir = manifest, out })
+	if st.files != 0 || st.skipped != 3 || len(st.failed) != 1 {
+		t.Error("resume:", st.Summary())
+	}
+	// world.txt without sha256 is verified by size, report.pdf by the name
+	// of Content-Disposition, so only they and the corrupt one are requested
+	if s.Gets-gets != 2 {
+		t.Error("files requested again:", s.Gets-gets)
+	}
+	writeFile(t, filepath.Join(out, "a", "hello.txt"), "HELLO")
+	st = export(t, s, func() { manifestPath, outDir = manifest, out })
+	if st.files != 1 {
+		t.Error("changed file should be downloaded again:", st.Summary())
+	}
+	checkFiles(t, out, map[string]string{"a/hello.txt": "hello"})
+}
+
+func TestSameNames(t *testing.T) {
+	s := fakeweed.New(map[string]string{"3,0112345678": "first", "3,0212345678": "second"})
+	s.Names["3,0112345678"], s.Names["3,0212345678"] = "index.html", "index.html"
+	defer s.Close()
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	manifest := filepath.Join(dir, "fids.txt")
+	out := filepath.Join(dir, "out")
+	writeFile(t, manifest, "3,0112345678\n3,0212345678\n")
+
+	st := export(t, s, func() { manifestPath, outDir = manifest, out })
+	if st.files != 2 || len(st.failed) != 0 {
+		t.Fatal(st.Summary())
+	}
+	got := map[string]bool{}
+	for _, name := range []string{"index.html", "index-3_0112345678.html", "index-3_0212345678.html"} {
+		if b, err := ioutil.ReadFile(filepath.Join(out, name)); err == nil {
+			got[string(b)] = true
+		}
+	}
+	if len(got) != 2 || !got["first"] || !got["second"] {
+		t.Error("files of the same name overwrite each other:", got)
+	}
+}
+
+func TestManifestFormats(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	csvPath := filepath.Join(dir, "files.csv")
+	writeFile(t, csvPath, "path,fid,size,mime,sha256,timestamp,error,mtime\n"+
+		"a.txt,\"3,0112345678\",5,,abc,,,\n"+
+		"b.txt,\"3,0212345678\",0,,,,oops,\n"+
+		"c.txt,,3,,,,,,/dest/c.txt\n")
+	linesPath := filepath.Join(dir, "files.txt")
+	writeFile(t, linesPath, "# fid path\n3,0112345678 dir/with space.txt\n3,0212345678\n\n")
+
+	for path, want := range map[string]string{
+		csvPath:   "3,0112345678 a.txt 5 abc|",
+		linesPath: "3,0112345678 dir/with space.txt -1 |3,0212345678  -1 |",
+	} {
+		got := ""
+		if err := readManifest(path, func(it *item) {
+			got += fmt.Sprintf("%s %s %d %s|", it.src, it.path, it.size, it.sha256)
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("%s: %q, want %q", filepath.Base(path), got, want)
+		}
+	}
+}
+
+func TestFiler(t *testing.T) {
+	s := fakeweed.New(map[string]string{"docs/a.txt": "a", "docs/x/b.txt": "b", "docs/x/y/c.txt": "c", "other/d.txt": "d"})
+	defer s.Close()
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	st := export(t, s, func() { filerUrl, src, outDir = s.URL, "/docs", dir })
+	if st.files != 3 || len(st.failed) != 0 {
+		t.Fatal(st.Summary())
+	}
+	checkFiles(t, dir, map[string]string{"a.txt": "a", "x/b.txt": "b", "x/y/c.txt": "c"})
+	if _, err := os.Stat(filepath.Join(dir, "d.txt")); !os.IsNotExist(err) {
+		t.Error("file out of -src downloaded")
+	}
+}
+
+func TestFilerPages(t *testing.T) {
+	files := map[string]string{"docs/x/a.txt": "a"}
+	for i := 0; i < 250; i++ {
+		files[fmt.Sprintf("docs/%03d.txt", i)] = strconv.Itoa(i)
+	}
+	s := fakeweed.New(files)
+	defer s.Close()
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	st := export(t, s, func() { filerUrl, src, outDir = s.URL, "/docs", dir })
+	if st.files != 251 || len(st.failed) != 0 {
+		t.Fatal(st.Summary())
+	}
+	// 3 pages of /docs/ and one of /docs/x/
+	if s.Pages != 4 {
+		t.Error("pages listed:", s.Pages)
+	}
+	checkFiles(t, dir, map[string]string{"000.txt": "0", "249.txt": "249", "x/a.txt": "a"})
+}
+
+func TestFilerResume(t *testing.T) {
+	s := fakeweed.New(map[string]string{"docs/a.txt": "aaa", "docs/x/b.txt": "bbb"})
+	defer s.Close()
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	set := func() { filerUrl, src, outDir = s.URL, "/docs", dir }
+
+	if st := export(t, s, set); st.files != 2 || len(st.failed) != 0 {
+		t.Fatal(st.Summary())
+	}
+	// b is cut by an interrupted run
+	writeFile(t, filepath.Join(dir, "x", "b.txt"), "b")
+	gets := s.Gets
+	if st := export(t, s, set); st.files != 1 || st.skipped != 1 || len(st.failed) != 0 {
+		t.Error("second run:", st.Summary())
+	}
+	if s.Gets-gets != 1 {
+		t.Error("only b should be downloaded again:", s.Gets-gets)
+	}
+	checkFiles(t, dir, map[string]string{"a.txt": "aaa", "x/b.txt": "bbb"})
+}
+
+func TestSetup(t *testing.T) {
+	for _, set := range []func(){
+		func() { outDir = "out" },
+		func() { src, outDir = "/docs", "out" },
+		func() { manifestPath = "files.jsonl" },
+	} {
+		server, filerUrl, src, manifestPath, outDir = "", "", "", "", ""
+		set()
+		if err := setup(); err == nil {
+			t.Error("invalid flags should fail", manifestPath, src, outDir)
+		}
+	}
+}
@@ -0,0 +1,314 @@
+// Download files listed in a manifest of uploader, or a filer directory,
+// into a local tree in parallel
+//
+//	downloader -manifest files.jsonl -out /restore
+//	downloader -filer localhost:8888 -src /docs -out /restore
+//
+// Files complete in -out are skipped, so an interrupted run is resumed by
+// running it again. Sizes and sha256 of the manifest are verified, as well as
+// etags of volume servers.
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Archs/weedo"
+	"github.com/Archs/weedo/internal/cliutil"
+)
+
+var (
+	server       string
+	filerUrl     string
+	src          string
+	manifestPath string
+	outDir       string
+	concurrency  int
+	overwrite    bool
+	debug        bool
+)
+
+var (
+	client *weedo.Client
+	filer  *weedo.Filer // nil unless -filer is set
+	// local paths of fids named by volume servers -> fid, see claim
+	namesMu sync.Mutex
+	names   map[string]string
+)
+
+func setup() error {
+	client = weedo.NewClient(server)
+	client.VerifyReads(true)
+	filer = nil
+	names = map[string]string{}
+	if filerUrl != "" {
+		filer = client.Filer(filerUrl)
+	}
+	if manifestPath == "" && src == "" {
+		return errors.New("-manifest or -src is required")
+	}
+	if src != "" && filer == nil {
+		return errors.New("-src requires -filer")
+	}
+	if outDir == "" {
+		return errors.New("-out is required")
+	}
+	return nil
+}
+
+type result struct {
+	item    *item
+	path    string // local path
+	size    int64
+	err     error
+	skipped bool // complete in the last run
+}
+
+// local path of it under -out, never outside
+func localPath(p string) string {
+	p = filepath.FromSlash(p)
+	p = strings.TrimPrefix(p, filepath.VolumeName(p))
+	return filepath.Join(outDir, filepath.Clean(string(filepath.Separator)+p))
+}
+
+// name of file without path in manifest
+func fileName(it *item, info *weedo.FileInfo) string {
+	if name := filepath.Base(filepath.FromSlash(info.Name)); info.Name != "" && name != "." && name != string(filepath.Separator) {
+		return name
+	}
+	return strings.Replace(it.src, ",", "_", -1)
+}
+
+// claim path for the file of fid named by its volume server. Another fid
+// of the same name gets the path with "-fid" before the extension instead
+// of overwriting the file, e.g. index-3_01637037d6.html
+func claim(path, fid string) string {
+	namesMu.Lock()
+	defer namesMu.Unlock()
+	if f, ok := names[path]; ok && f != fid {
+		ext := filepath.Ext(path)
+		path = strings.TrimSuffix(path, ext) + "-" + strings.Replace(fid, ",", "_", -1) + ext
+	}
+	names[path] = fid
+	return path
+}
+
+// is the file at path complete with size and sha256 if they are known
+func complete(path string, size int64, sum string) bool {
+	if overwrite {
+		return false
+	}
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() || size >= 0 && info.Size() != size {
+		return false
+	}
+	if size < 0 && sum == "" {
+		return false
+	}
+	if sum != "" {
+		s, err := cliutil.SHA256File(path)
+		return err == nil && s == sum
+	}
+	return true
+}
+
+// size of src by a HEAD request, -1 if unknown
+func headSize(src string) int64 {
+	var info *weedo.FileInfo
+	var err error
+	if strings.HasPrefix(src, "/") {
+		if filer == nil {
+			return -1
+		}
+		info, err = filer.Head(src)
+	} else {
+		info, err = client.Head(src)
+	}
+	if err != nil {
+		return -1
+	}
+	return info.Size
+}
+
+func open(src string) (io.ReadCloser, *weedo.FileInfo, error) {
+	if strings.HasPrefix(src, "/") {
+		if filer == nil {
+			return nil, nil, errors.New(src + ": -filer is required for filer paths")
+		}
+		return filer.Get(src)
+	}
+	return client.Get(src)
+}
+
+// download it into a .part file, which is renamed once verified
+func download(it *item) (r result) {
+	r = result{item: it}
+	size := it.size
+	if it.path != "" {
+		r.path = localPath(it.path)
+		if _, err := os.Stat(r.path); err == nil && size < 0 && it.sha256 == "" && !overwrite {
+			// listed without size, such as files in filer
+			size = headSize(it.src)
+		}
+		if complete(r.path, size, it.sha256) {
+			r.size, r.skipped = size, true
+			return
+		}
+	}
+	body, info, err := open(it.src)
+	if err != nil {
+		r.err = err
+		return
+	}
+	defer body.Close()
+	if size < 0 {
+		size = info.Size
+	}
+	if r.path == "" {
+		r.path = claim(localPath(fileName(it, info)), it.src)
+		if complete(r.path, size, it.sha256) {
+			r.size, r.skipped = size, true
+			return
+		}
+	}
+	if debug {
+		log.Println("\t", it.src, "=>", r.path)
+	}
+
+	if r.err = os.MkdirAll(filepath.Dir(r.path), 0755); r.err != nil {
+		return
+	}
+	part := r.path + ".part"
+	file, err := os.Create(part)
+	if err != nil {
+		r.err = err
+		return
+	}
+	h := sha256.New()
+	r.size, r.err = io.Copy(io.MultiWriter(file, h), body)
+	if err := file.Close(); r.err == nil {
+		r.err = err
+	}
+	if r.err == nil && size >= 0 && r.size != size {
+		r.err = fmt.Errorf("size %d, expected %d", r.size, size)
+	}
+	if sum := hex.EncodeToString(h.Sum(nil)); r.err == nil && it.sha256 != "" && sum != it.sha256 {
+		r.err = fmt.Errorf("sha256 %s, expected %s", sum, it.sha256)
+	}
+	if r.err == nil {
+		r.err = os.Rename(part, r.path)
+	}
+	if r.err != nil {
+		os.Remove(part)
+	}
+	return
+}
+
+// counters of a download run
+type stats struct {
+	start   time.Time
+	files   int
+	bytes   int64
+	skipped int
+	failed  []result
+}
+
+func (s *stats) Summary() string {
+	d := time.Since(s.start)
+	return fmt.Sprintf("%d files, %d bytes downloaded in %s, %d skipped, %d failed",
+		s.files, s.bytes, d.Truncate(time.Millisecond), s.skipped, len(s.failed))
+}
+
+// download files of the manifest or filer with -concurrency workers
+func run() *stats {
+	st := &stats{start: time.Now()}
+	items := make(chan *item, concurrency)
+	results := make(chan result)
+
+	// list
+	go func() {
+		enqueue := func(it *item) { items <- it }
+		var err error
+		if manifestPath != "" {
+			err = readManifest(manifestPath, enqueue)
+		} else {
+			err = listFiler(filer, src, enqueue)
+		}
+		if err != nil {
+			source := manifestPath
+			if source == "" {
+				source = src
+			}
+			results <- result{item: &item{src: source}, err: err}
+		}
+		close(items)
+	}()
+
+	// download
+	var wg sync.WaitGroup
+	n := concurrency
+	if n < 1 {
+		n = 1
+	}
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for it := range items {
+				results <- download(it)
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	for r := range results {
+		switch {
+		case r.err != nil:
+			log.Printf("Downloading %s error:%s", r.item.src, r.err.Error())
+			st.failed = append(st.failed, r)
+		case r.skipped:
+			st.skipped++
+		default:
+			st.files++
+			st.bytes += r.size
+		}
+	}
+	return st
+}
+
+func main() {
+	flag.Parse()
+	if err := setup(); err != nil {
+		log.Fatal(err)
+	}
+	st := run()
+	log.Println(st.Summary())
+	if len(st.failed) > 0 {
+		os.Exit(1)
+	}
+}
+
+func init() {
+	flag.StringVar(&server, "server", "localhost:9333", "SeaweedFS master location")
+	flag.StringVar(&filerUrl, "filer", "", "SeaweedFS filer location, for -src and filer paths in the manifest")
+	flag.StringVar(&src, "src", "", "filer directory to download recursively")
+	flag.StringVar(&manifestPath, "manifest", "", "manifest of uploader, JSON lines, CSV if it ends with .csv, or lines of \"fid [path]\"")
+	flag.StringVar(&outDir, "out", "", "local directory to download into")
+	flag.IntVar(&concurrency, "concurrency", 4, "number of files downloaded in parallel")
+	flag.BoolVar(&overwrite, "overwrite", false, "download files complete in -out again")
+	flag.BoolVar(&debug, "debug", false, "verbose debug information")
+	log.SetFlags(log.Ldate | log.Ltime)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"log"
+	"path"
+
+	"github.com/Archs/weedo"
+	"github.com/Archs/weedo/internal/manifest"
+)
+
+// File to download
+type item struct {
+	src    string // fid, or path in filer starting with a slash
+	path   string // local path under -out, empty for the name from Content-Disposition
+	size   int64  // -1 if unknown
+	sha256 string
+}
+
+// file of manifest entry e, nil if it's not uploaded
+func newItem(e *manifest.Entry) *item {
+	if e.Error != "" || e.Fid == "" && e.Dest == "" {
+		return nil
+	}
+	it := &item{src: e.Fid, path: e.Path, size: e.Size, sha256: e.Sha256}
+	if it.src == "" {
+		it.src = e.Dest
+	}
+	if e.Text != "" {
+		// line of "fid [path]"
+		it.path = e.Text
+	}
+	if e.Size == 0 && e.Sha256 == "" {
+		it.size = -1
+	}
+	return it
+}
+
+// Read files of manifest written by uploader, as JSON lines, CSV if path
+// ends with .csv, or lines of "fid [path]". Paths uploaded more than once by
+// resumed runs are enqueued once with the last entry
+func readManifest(fpath string, enqueue func(*item)) error {
+	entries, err := manifest.ReadFile(fpath)
+	if err != nil {
+		return err
+	}
+	items := []*item{}
+	index := map[string]int{}
+	for _, e := range entries {
+		it := newItem(e)
+		if it == nil {
+			continue
+		}
+		key := it.path
+		if key == "" {
+			key = it.src
+		}
+		if i, ok := index[key]; ok {
+			items[i] = it
+			continue
+		}
+		index[key] = len(items)
+		items = append(items, it)
+	}
+	for _, it := range items {
+		enqueue(it)
+	}
+	return nil
+}
+
+// List files in dir of filer recursively, paths are kept relative to dir
+func listFiler(filer *weedo.Filer, dir string, enqueue func(*item)) error {
+	return walkFiler(filer, path.Clean("/"+dir), "", enqueue)
+}
+
+func walkFiler(filer *weedo.Filer, root, rel string, enqueue func(*item)) error {
+	dir, err := filer.Dir(path.Join(root, rel))
+	if err != nil {
+		return err
+	}
+	for _, f := range dir.Files {
+		p := path.Join(rel, f.Name)
+		enqueue(&item{src: path.Join(root, p), path: p, size: -1})
+	}
+	for _, d := range dir.Subdirs {
+		if err := walkFiler(filer, root, path.Join(rel, d.Name), enqueue); err != nil {
+			log.Printf("Listing %s error:%s", path.Join(root, rel, d.Name), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// file of patterns to skip in a directory and its subdirectories, with the
+// syntax of .gitignore
+const ignoreFile = ".weedignore"
+
+type ignoreRule struct {
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// parse a line of .weedignore, nil for blank lines and comments
+func parseIgnoreRule(line string) *ignoreRule {
+	// trailing spaces are ignored unless escaped
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, `\ `) {
+		line = line[:len(line)-1]
+	}
+	if line == "" || line[0] == '#' {
+		return nil
+	}
+	rule := &ignoreRule{}
+	if line[0] == '!' {
+		rule.negate = true
+		line = line[1:]
+	} else if line[0] == '\\' && len(line) > 1 && (line[1] == '!' || line[1] == '#') {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return nil
+	}
+	// patterns with a slash are relative to the directory of .weedignore,
+	// the others match at any level below it
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	expr := globRegexp(line)
+	if !anchored {
+		expr = "(.*/)?" + expr
+	}
+	re, err := regexp.Compile("^" + expr + "$")
+	if err != nil {
+		return nil
+	}
+	rule.re = re
+	return rule
+}
+
+// translate glob with ** into regexp on slash separated paths
+func globRegexp(glob string) string {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case c == '*' && strings.HasPrefix(glob[i:], "**/"):
+			b.WriteString("(.*/)?")
+			i += 2
+		case c == '*' && strings.HasPrefix(glob[i:], "**") && i+2 == len(glob):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '\\' && i+1 < len(glob):
+			i++
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		case c == '[':
+			j := strings.IndexByte(glob[i+1:], ']')
+			if j < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+j]
+			i += j + 1
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
+
+// rules of .weedignore files by directory
+type ignores struct {
+	rules map[string][]*ignoreRule
+}
+
+func newIgnores() *ignores {
+	return &ignores{rules: map[string][]*ignoreRule{}}
+}
+
+// load .weedignore of dir if it exists
+func (ig *ignores) load(dir string) error {
+	file, err := os.Open(filepath.Join(dir, ignoreFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+	var rules []*ignoreRule
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if rule := parseIgnoreRule(strings.TrimSuffix(scanner.Text(), "\r")); rule != nil {
+			rules = append(rules, rule)
+		}
+	}
+	if len(rules) > 0 {
+		ig.rules[filepath.Clean(dir)] = rules
+	}
+	return scanner.Err()
+}
+
+// is path ignored by the .weedignore files of its parent directories, the
+// last matching rule wins and rules of deeper directories come later
+func (ig *ignores) ignored(path string, isDir bool) bool {
+	if len(ig.rules) == 0 {
+		return false
+	}
+	path = filepath.Clean(path)
+	var dirs []string
+	for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+		dirs = append(dirs, dir)
+		if parent := filepath.Dir(dir); parent == dir {
+			break
+		}
+	}
+	ignored := false
+	for i := len(dirs) - 1; i >= 0; i-- {
+		rules := ig.rules[dirs[i]]
+		if len(rules) == 0 {
+			continue
+		}
+		rel, err := filepath.Rel(dirs[i], path)
+		if err != nil {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		for _, rule := range rules {
+			if rule.dirOnly && !isDir {
+				continue
+			}
+			if rule.re.MatchString(rel) {
+				ignored = !rule.negate
+			}
+		}
+	}
+	return ignored
+}
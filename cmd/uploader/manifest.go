@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Archs/weedo/internal/manifest"
+)
+
+func newEntry(r result) *manifest.Entry {
+	e := &manifest.Entry{
+		Path:   r.path,
+		Fid:    r.fid,
+		Size:   r.size,
+		Mime:   r.mime,
+		Sha256: r.sha256,
+		Time:   r.time,
+		Mtime:  r.mtime,
+		Dest:   r.dest,
+	}
+	if r.err != nil {
+		e.Error = r.err.Error()
+	}
+	return e
+}
+
+// The last entries of manifest by absolute path, empty if it doesn't exist
+func loadManifest(path string) (map[string]*manifest.Entry, error) {
+	entries, err := manifest.ReadFile(path)
+	if os.IsNotExist(err) {
+		return map[string]*manifest.Entry{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	last := make(map[string]*manifest.Entry)
+	for _, e := range entries {
+		if abs, err := filepath.Abs(e.Path); err == nil {
+			last[abs] = e
+		}
+	}
+	return last, nil
+}
+
+// Manifest of uploaded files, every result is written to the file as soon
+// as it's done, so it's kept even if the uploader crashes
+type manifestWriter struct {
+	file    *os.File
+	csv     *csv.Writer // nil for JSON lines
+	resumed bool        // resumed from, skipped files are not written again
+}
+
+// Open manifest for appending, CSV if path ends with .csv
+func openManifest(path string) (*manifestWriter, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	m := &manifestWriter{file: file}
+	if strings.HasSuffix(strings.ToLower(path), ".csv") {
+		m.csv = csv.NewWriter(file)
+		if info, err := file.Stat(); err == nil && info.Size() == 0 {
+			m.csv.Write(manifest.CSVHeader)
+		}
+	}
+	return m, nil
+}
+
+func (m *manifestWriter) Write(r result) error {
+	e := newEntry(r)
+	if m.csv == nil {
+		return json.NewEncoder(m.file).Encode(e)
+	}
+	m.csv.Write(e.Record())
+	m.csv.Flush()
+	return m.csv.Error()
+}
+
+func (m *manifestWriter) Close() error {
+	if m == nil || m.file == nil {
+		return nil
+	}
+	err := m.file.Close()
+	m.file = nil
+	return err
+}
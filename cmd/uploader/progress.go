@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Archs/weedo/internal/cliutil"
+)
+
+type result struct {
+	path   string
+	fid    string
+	dest   string // path in filer
+	size   int64
+	mime   string
+	sha256 string
+	mtime  time.Time
+	time   time.Time // when the upload finished
+	err    error
+	// resuming
+	skipped bool // uploaded in the last run and unchanged
+	touched bool // mtime changed only
+	changed bool // changed since uploaded in the last run
+}
+
+// fid or filer path the file is uploaded to
+func (r result) target() string {
+	if r.dest != "" {
+		return r.dest
+	}
+	return r.fid
+}
+
+// counters of an upload run, safe for concurrent use
+type stats struct {
+	mu        sync.Mutex
+	start     time.Time
+	files     int // found
+	bytes     int64
+	scanned   bool // all files are found
+	doneFiles int
+	doneBytes int64
+	failed    []result
+	skipped   int
+	// bytes of skipped files, done without upload, so not in the rate
+	skippedBytes int64
+	changed      int
+}
+
+func newStats() *stats {
+	return &stats{start: time.Now()}
+}
+
+func (s *stats) found(size int64) {
+	s.mu.Lock()
+	s.files++
+	s.bytes += size
+	s.mu.Unlock()
+}
+
+func (s *stats) scanDone() {
+	s.mu.Lock()
+	s.scanned = true
+	s.mu.Unlock()
+}
+
+func (s *stats) done(r result) {
+	s.mu.Lock()
+	s.doneFiles++
+	s.doneBytes += r.size
+	if r.err != nil {
+		s.failed = append(s.failed, r)
+	}
+	if r.skipped || r.touched {
+		s.skipped++
+		s.skippedBytes += r.size
+	}
+	if r.changed {
+		s.changed++
+	}
+	s.mu.Unlock()
+}
+
+// progress line like: 12/100 files, 1.2 MB/10.0 MB, 300.0 KB/s, ETA 30s
+func (s *stats) Progress() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	elapsed := time.Since(s.start)
+	rate := float64(s.doneBytes-s.skippedBytes) / elapsed.Seconds()
+	eta := "scanning"
+	if s.scanned {
+		eta = "ETA -"
+		if rate > 0 {
+			left := time.Duration(float64(s.bytes-s.doneBytes) / rate * float64(time.Second))
+			eta = "ETA " + left.Truncate(time.Second).String()
+		}
+	}
+	return fmt.Sprintf("%d/%d files, %s/%s, %s/s, %s, %d failed   ",
+		s.doneFiles, s.files, cliutil.HumanBytes(s.doneBytes), cliutil.HumanBytes(s.bytes),
+		cliutil.HumanBytes(int64(rate)), eta, len(s.failed))
+}
+
+// summary of the run with the failed files
+func (s *stats) Summary() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	elapsed := time.Since(s.start)
+	uploaded := s.doneBytes - s.skippedBytes - s.failedBytes()
+	b := strings.Builder{}
+	fmt.Fprintf(&b, "%d files, %s uploaded in %s, %s/s, %d failed",
+		s.doneFiles-s.skipped-len(s.failed), cliutil.HumanBytes(uploaded),
+		elapsed.Truncate(time.Millisecond), cliutil.HumanBytes(int64(float64(uploaded)/elapsed.Seconds())),
+		len(s.failed))
+	if s.skipped > 0 || s.changed > 0 {
+		fmt.Fprintf(&b, ", %d unchanged skipped, %d changed uploaded again", s.skipped, s.changed)
+	}
+	for _, r := range s.failed {
+		fmt.Fprintf(&b, "\n\tfailed: %s: %s", r.path, r.err)
+	}
+	return b.String()
+}
+
+func (s *stats) failedBytes() (n int64) {
+	for _, r := range s.failed {
+		n += r.size
+	}
+	return
+}
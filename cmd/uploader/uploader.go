@@ -1,11 +1,22 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"flag"
+	"fmt"
 	"github.com/Archs/weedo"
+	"github.com/Archs/weedo/internal/cliutil"
+	"github.com/Archs/weedo/internal/manifest"
+	"io"
 	"log"
+	"mime"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 )
 
 // -collection string
@@ -31,94 +42,378 @@ var (
 	server      string
 	debug       bool
 	recursive   bool
+	dir         string
 	collection  string
 	replication string
+	include     string
+	maxMB       int
+	secret      string
+	ttl         string
+	concurrency int
+	// follow or skip symlinks in directories
+	symlinks string
+	// upload hidden files and directories, whose names start with a dot
+	hidden bool
+	// levels of subdirectories to walk into, negative for unlimited
+	maxDepth int
+	// upload into the filer under dest instead of raw fids
+	filerUrl string
+	dest     string
+	// overwrite or skip files existing in the filer
+	existing string
+	// results of each file as JSON lines, or CSV if it ends with .csv
+	manifestPath string
+	// manifest of the last run to resume
+	resumePath string
+	// node id of timekey fids, random if negative
+	node int
 )
 
+// policies of -existing
+const (
+	existingOverwrite = "overwrite"
+	existingSkip      = "skip"
+)
+
+// seconds before jwt signed by -secure.secret expire, the same as seaweedfs
+const jwtExpiresAfterSec = 10
+
 var (
 	client *weedo.Client
+	filer  *weedo.Filer // nil unless -filer is set
+	opt    *weedo.UploadOption
 	fmap   = map[string]string{} // map fid -> filepath
+	// interval of progress line, 0 to disable
+	progressInterval = time.Second
 )
 
-func uploadFile(path string) error {
-	log.Println("\t", path, "...")
-	fid, err := client.UploadFileTK(path)
+// create client and upload option from flags
+func setup() (err error) {
+	client = weedo.NewClient(server)
+	if secret != "" {
+		client.SetJwtSigningKey(secret, "", jwtExpiresAfterSec)
+	}
+	if node >= 0 {
+		if err = client.SetTimekeyNode(node); err != nil {
+			return fmt.Errorf("invalid -node %d: %s", node, err)
+		}
+	}
+	opt = &weedo.UploadOption{
+		Collection:   collection,
+		Replication:  replication,
+		MaxChunkSize: int64(maxMB) << 20,
+		// fid uploads only, the filer returns no etag to verify
+		Verify: true,
+	}
+	if opt.Ttl, err = weedo.ParseTTL(ttl); err != nil {
+		return
+	}
+	filer = nil
+	if filerUrl != "" {
+		if existing != existingOverwrite && existing != existingSkip {
+			return fmt.Errorf("invalid -existing %q, should be %s or %s", existing, existingOverwrite, existingSkip)
+		}
+		filer = client.Filer(filerUrl)
+	}
+	return
+}
+
+// is the file included by -include patterns, which match the base name
+func included(path string) bool {
+	if include == "" {
+		return true
+	}
+	name := filepath.Base(path)
+	for _, pattern := range strings.Split(include, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// upload file of j, with sha256 computed while uploading
+func uploadFile(j job) (r result) {
+	path := j.path
+	r = result{path: path, size: j.size, mtime: j.mtime}
+	if filer != nil {
+		r.dest = j.dest
+		if existing == existingSkip {
+			if ok, err := filer.Exists(j.dest); err != nil || ok {
+				r.err, r.skipped = err, ok
+				if ok && debug {
+					log.Println("\t", path, "skipped, exists in filer as", j.dest)
+				}
+				return
+			}
+		}
+	}
+	if debug {
+		log.Println("\t", path, "...")
+	}
+	file, err := os.Open(path)
 	if err != nil {
-		return err
+		r.err = err
+		return
+	}
+	defer file.Close()
+	h := sha256.New()
+	if filer != nil {
+		r.mime = mime.TypeByExtension(filepath.Ext(path))
+		r.err = filer.UploadWithOption(j.dest, r.mime, io.TeeReader(file, h), opt)
+	} else {
+		r.fid, r.err = client.AssignUploadTKWithOption(filepath.Base(path), io.TeeReader(file, h), int(j.size), opt)
+	}
+	r.time = time.Now()
+	if r.err != nil {
+		return
+	}
+	r.sha256 = hex.EncodeToString(h.Sum(nil))
+	if fid, err := weedo.ParseFid(r.fid); err == nil {
+		r.mime = fid.MimeType()
 	}
-	fmap[fid] = path
-	return nil
+	if debug {
+		log.Println("\t", path, "=>", r.target())
+	}
+	return
+}
+
+// path in filer of the file at path found in root, which is a directory to
+// upload or the file itself
+func destPath(root, file string) string {
+	rel := filepath.Base(file)
+	if root != file {
+		if r, err := filepath.Rel(root, file); err == nil {
+			rel = r
+		}
+	}
+	return path.Join("/", dest, filepath.ToSlash(rel))
+}
+
+type job struct {
+	path  string
+	size  int64
+	mtime time.Time
+	dest  string          // path in filer
+	prev  *manifest.Entry // entry of the last run to resume
 }
 
-func uploadDirectory(dirPath string) error {
-	if !recursive {
-		log.Println(dirPath, "is a directory")
-		return nil
+// upload the file of j unless it's uploaded in the last run and unchanged
+func process(j job) result {
+	e := j.prev
+	if e == nil || e.Error != "" {
+		return uploadFile(j)
+	}
+	// uploaded as a fid or to another filer path by the last run
+	if filer != nil && e.Dest != j.dest || filer == nil && e.Fid == "" {
+		return uploadFile(j)
 	}
-	walkFn := func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
+	if e.Size == j.size && e.Mtime.Equal(j.mtime) {
+		return result{path: j.path, fid: e.Fid, dest: e.Dest, size: j.size, mtime: e.Mtime,
+			mime: e.Mime, sha256: e.Sha256, time: e.Time, skipped: true}
+	}
+	if e.Size == j.size && e.Sha256 != "" {
+		// touched only, keep the fid with new mtime
+		if sum, err := cliutil.SHA256File(j.path); err == nil && sum == e.Sha256 {
+			return result{path: j.path, fid: e.Fid, dest: e.Dest, size: j.size, mtime: j.mtime,
+				mime: e.Mime, sha256: sum, time: e.Time, touched: true}
 		}
-		// for dir
-		if info.IsDir() {
-			if path == dirPath {
-				return nil
+	}
+	log.Println("\t", j.path, "changed since uploaded as", e.Target())
+	r := uploadFile(j)
+	r.changed = true
+	return r
+}
+
+// upload paths with -concurrency workers, fids are saved in fmap and
+// results are written to m if it's not nil. Files in last, the entries of
+// the last run by absolute path, are skipped if unchanged
+func run(paths []string, m *manifestWriter, last map[string]*manifest.Entry) *stats {
+	st := newStats()
+	jobs := make(chan job, concurrency)
+	results := make(chan result)
+
+	// walk
+	go func() {
+		var root string
+		enqueue := func(path string, info os.FileInfo) {
+			st.found(info.Size())
+			j := job{path: path, size: info.Size(), mtime: info.ModTime()}
+			if filer != nil {
+				j.dest = destPath(root, path)
 			}
-			err = uploadDirectory(path)
+			if abs, err := filepath.Abs(path); err == nil {
+				j.prev = last[abs]
+			}
+			jobs <- j
+		}
+		for _, fpath := range paths {
+			root = fpath
+			info, err := os.Stat(fpath)
 			if err != nil {
-				log.Printf("Uploading dir:%s error:%s", path, err.Error())
+				st.found(0)
+				results <- result{path: fpath, err: err, time: time.Now()}
+				continue
+			}
+			if info.IsDir() {
+				err = uploadDirectory(fpath, enqueue)
+			} else {
+				enqueue(fpath, info)
+			}
+			if err != nil {
+				log.Println("Uploading", fpath, "failed:", err.Error())
 			}
-			return nil
 		}
-		// for file
-		err = uploadFile(path)
-		if err != nil {
-			log.Printf("Uploading file:%s error:%s", path, err.Error())
+		st.scanDone()
+		close(jobs)
+	}()
+
+	// upload
+	var wg sync.WaitGroup
+	n := concurrency
+	if n < 1 {
+		n = 1
+	}
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				results <- process(j)
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// progress
+	var tick <-chan time.Time
+	if progressInterval > 0 {
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case r, ok := <-results:
+			if !ok {
+				if tick != nil {
+					fmt.Fprintln(os.Stderr)
+				}
+				return st
+			}
+			if r.err != nil {
+				log.Printf("Uploading file:%s error:%s", r.path, r.err.Error())
+			} else {
+				fmap[r.target()] = r.path
+			}
+			// skipped files are in the manifest resumed from already
+			if m != nil && (!r.skipped || !m.resumed) {
+				if err := m.Write(r); err != nil {
+					log.Println("Writing manifest error:", err)
+				}
+			}
+			st.done(r)
+		case <-tick:
+			fmt.Fprint(os.Stderr, "\r", st.Progress())
 		}
-		return nil
 	}
-	log.Println("Uploading directory:", dirPath, "...")
-	return filepath.Walk(dirPath, walkFn)
+}
+
+// are paths of the same existing file
+func sameFile(a, b string) bool {
+	ia, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	ib, err := os.Stat(b)
+	return err == nil && os.SameFile(ia, ib)
+}
+
+// paths to upload, -dir is uploaded recursively
+func targets(args []string) []string {
+	if dir != "" {
+		recursive = true
+		args = append(args, dir)
+	}
+	return args
 }
 
 func main() {
 	flag.Parse()
-	client = weedo.NewClient(server)
-	if err := client.Master().Status(); err != nil {
-		log.Fatal("invalid client:", err)
+	if err := setup(); err != nil {
+		log.Fatal(err)
+	}
+	if filer == nil {
+		if err := client.Master().Status(); err != nil {
+			log.Fatal("invalid client:", err)
+		}
 	}
 	// ok now
-	if len(flag.Args()) <= 0 {
+	paths := targets(flag.Args())
+	if len(paths) <= 0 {
 		log.Fatalln("no files or directories specified")
 	}
-	// do upload
-	for _, fpath := range flag.Args() {
-		info, err := os.Stat(fpath)
-		if err != nil {
-			log.Printf("Uploading %s error:%s", fpath, err.Error())
-			continue
+	var last map[string]*manifest.Entry
+	if resumePath != "" {
+		var err error
+		if last, err = loadManifest(resumePath); err != nil {
+			log.Fatal(err)
 		}
-		if info.IsDir() {
-			err = uploadDirectory(fpath)
-		} else {
-			err = uploadFile(fpath)
+		if manifestPath == "" {
+			manifestPath = resumePath
 		}
-		if err != nil {
-			log.Println("Uploading", fpath, "failed:", err.Error())
+	}
+	var m *manifestWriter
+	if manifestPath != "" {
+		var err error
+		if m, err = openManifest(manifestPath); err != nil {
+			log.Fatal(err)
 		}
+		m.resumed = sameFile(manifestPath, resumePath)
+		defer m.Close()
 	}
+	// do upload
+	st := run(paths, m, last)
 	// print out fids
 	log.Println("Upload done:")
 	for fid, fpath := range fmap {
 		log.Printf("<<<%s>>>\t%s\n", fid, fpath)
 	}
+	log.Println(st.Summary())
+	if len(st.failed) > 0 {
+		m.Close()
+		os.Exit(1)
+	}
 }
 
 func init() {
 	flag.StringVar(&server, "server", "http://localhost:9333", `SeaweedFS master location`)
-	flag.StringVar(&collection, "col", "", `optional collection name`)
+	flag.StringVar(&collection, "collection", "", `optional collection name`)
+	flag.StringVar(&collection, "col", "", `alias of -collection`)
 	flag.StringVar(&replication, "replication", "", "replication type")
+	flag.StringVar(&dir, "dir", "", "Upload the whole folder recursively if specified.")
+	flag.StringVar(&include, "include", "", `pattens of files to upload, e.g., *.pdf, *.html, ab?d.txt, works together with -dir and -r`)
+	flag.IntVar(&maxMB, "maxMB", 0, "split files larger than the limit")
+	flag.StringVar(&secret, "secure.secret", "", "secret to encrypt Json Web Token(JWT)")
+	flag.StringVar(&ttl, "ttl", "", "time to live, e.g.: 1m, 1h, 1d, 1M, 1y")
+	flag.IntVar(&concurrency, "concurrency", 4, "number of files uploaded in parallel")
+	flag.IntVar(&node, "node", -1, "node id of fid keys in [0, 1023], unique among uploaders running at the same time, random if negative")
+	flag.StringVar(&manifestPath, "manifest", "", "append results of each file to the manifest as JSON lines, or CSV if it ends with .csv")
+	flag.StringVar(&resumePath, "resume", "", "skip files uploaded and unchanged in the manifest of the last run, new results are appended to it unless -manifest is set")
+	flag.StringVar(&filerUrl, "filer", "", "upload into the filer at host:port, recreating the local directories under -dest")
+	flag.StringVar(&dest, "dest", "/", "filer directory to upload into, works together with -filer")
+	flag.StringVar(&existing, "existing", existingOverwrite, "overwrite or skip files existing in the filer, works together with -filer")
 	flag.BoolVar(&debug, "debug", false, "verbose debug information")
+	flag.StringVar(&symlinks, "symlinks", symlinkFollow, "follow or skip symlinks found in directories")
+	flag.BoolVar(&hidden, "hidden", true, "upload hidden files and directories, use -hidden=false to skip them")
+	flag.IntVar(&maxDepth, "maxDepth", -1, "levels of subdirectories to walk into, 0 for files in the directory only, negative for unlimited")
 	flag.BoolVar(&recursive, "r", false, `upload directory recursivly (default false)`)
 	// log opt
 	log.SetFlags(log.Ldate | log.Ltime)
@@ -0,0 +1,537 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Archs/weedo/internal/fakeweed"
+	"github.com/Archs/weedo/internal/manifest"
+	"github.com/Archs/weedo/timekey"
+)
+
+// set flags, run setup against a fake server, and upload paths
+func upload(t *testing.T, set func(), paths ...string) *fakeweed.Server {
+	s := fakeweed.New(nil)
+	server, collection, replication, include, maxMB, secret, ttl = s.URL, "", "", "", 0, "", ""
+	recursive, concurrency, progressInterval = true, 1, 0
+	symlinks, hidden, maxDepth = symlinkFollow, true, -1
+	filerUrl, dest, existing, node = "", "/", existingOverwrite, -1
+	fmap = map[string]string{}
+	set()
+	if err := setup(); err != nil {
+		t.Fatal(err)
+	}
+	if st := run(paths, nil, nil); len(st.failed) > 0 {
+		t.Fatal(st.Summary())
+	}
+	return s
+}
+
+func tempDir(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "uploader")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestCollectionReplicationTTL(t *testing.T) {
+	s := upload(t, func() {
+		collection, replication, ttl = "pics", "001", "3d"
+	}, "uploader.go")
+	defer s.Close()
+	if len(s.Assigns) != 1 {
+		t.Fatal("assigns:", len(s.Assigns))
+	}
+	q := s.Assigns[0]
+	if q.Get("collection") != "pics" || q.Get("replication") != "001" || q.Get("ttl") != "3d" {
+		t.Error("assign query not match", q)
+	}
+	if s.Uploads[0].URL.Query().Get("ttl") != "3d" {
+		t.Error("upload ttl not match", s.Uploads[0].URL)
+	}
+}
+
+func TestInvalidTTL(t *testing.T) {
+	ttl = "3x"
+	defer func() { ttl = "" }()
+	if err := setup(); err == nil {
+		t.Error("invalid -ttl should fail")
+	}
+}
+
+func TestNode(t *testing.T) {
+	s := upload(t, func() { node = 42 }, "uploader.go")
+	defer s.Close()
+	for fid := range fmap {
+		f, err := timekey.ParseFid(fid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.Node() != 42 {
+			t.Error("node not match", fid, f.Node())
+		}
+	}
+	if len(fmap) != 1 {
+		t.Error("uploaded:", len(fmap))
+	}
+
+	node = timekey.MaxNode + 1
+	defer func() { node = -1 }()
+	if err := setup(); err == nil {
+		t.Error("invalid -node should fail")
+	}
+}
+
+func TestInclude(t *testing.T) {
+	dir := tempDir(t, map[string]string{"a.pdf": "a", "b.html": "b", "abcd.txt": "c", "e.txt": "e"})
+	defer os.RemoveAll(dir)
+	s := upload(t, func() {
+		include = "*.pdf, *.html,ab?d.txt"
+	}, dir)
+	defer s.Close()
+	names := []string{}
+	for _, path := range fmap {
+		names = append(names, filepath.Base(path))
+	}
+	if len(names) != 3 {
+		t.Error("included files not match", names)
+	}
+	for _, name := range names {
+		if name == "e.txt" {
+			t.Error("e.txt should be excluded")
+		}
+	}
+}
+
+func TestMaxMB(t *testing.T) {
+	content := strings.Repeat("x", 2<<20+1)
+	dir := tempDir(t, map[string]string{"big.bin": content})
+	defer os.RemoveAll(dir)
+	s := upload(t, func() {
+		maxMB = 1
+	}, filepath.Join(dir, "big.bin"))
+	defer s.Close()
+	// 3 chunks and the manifest
+	if len(s.Uploads) != 4 {
+		t.Fatal("uploads:", len(s.Uploads))
+	}
+	manifest := s.Uploads[3]
+	if manifest.URL.Query().Get("cm") != "true" {
+		t.Error("manifest should be uploaded with cm=true", manifest.URL)
+	}
+	for fid := range fmap {
+		cm := struct {
+			Size   int64
+			Chunks []struct{ Fid string }
+		}{}
+		if err := json.Unmarshal([]byte(s.Files[fid]), &cm); err != nil {
+			t.Fatal(err)
+		}
+		if cm.Size != int64(len(content)) || len(cm.Chunks) != 3 {
+			t.Error("manifest not match", cm)
+		}
+	}
+}
+
+func TestSecret(t *testing.T) {
+	s := upload(t, func() {
+		secret = "secret"
+	}, "uploader.go")
+	defer s.Close()
+	auth := s.Uploads[0].Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") || strings.Count(auth, ".") != 2 {
+		t.Error("jwt not sent", auth)
+	}
+
+	s = upload(t, func() {}, "uploader.go")
+	defer s.Close()
+	if auth := s.Uploads[0].Header.Get("Authorization"); auth != "" {
+		t.Error("jwt sent without -secure.secret", auth)
+	}
+}
+
+func TestDir(t *testing.T) {
+	dir, recursive = "docs", false
+	defer func() { dir = "" }()
+	paths := targets([]string{"a.txt"})
+	if len(paths) != 2 || paths[1] != "docs" || !recursive {
+		t.Error("-dir not uploaded recursively", paths, recursive)
+	}
+}
+
+func TestConcurrency(t *testing.T) {
+	files := map[string]string{}
+	for i := 0; i < 50; i++ {
+		files[fmt.Sprintf("%02d.txt", i)] = strings.Repeat("x", i)
+	}
+	dir := tempDir(t, files)
+	defer os.RemoveAll(dir)
+	s := upload(t, func() {
+		concurrency = 8
+	}, dir)
+	defer s.Close()
+	if len(fmap) != 50 || len(s.Uploads) != 50 {
+		t.Error("uploaded files not match", len(fmap), len(s.Uploads))
+	}
+	for fid, path := range fmap {
+		if s.Files[fid] != files[filepath.Base(path)] {
+			t.Error("content not match", path)
+		}
+	}
+}
+
+func TestStats(t *testing.T) {
+	st := newStats()
+	st.found(1 << 20)
+	st.found(3 << 20)
+	if p := st.Progress(); !strings.HasPrefix(p, "0/2 files, 0 B/4.0 MiB") || !strings.Contains(p, "scanning") {
+		t.Error("progress not match", p)
+	}
+	st.scanDone()
+	st.done(result{path: "a", size: 1 << 20})
+	st.done(result{path: "b", size: 3 << 20, err: fmt.Errorf("boom")})
+	if p := st.Progress(); !strings.HasPrefix(p, "2/2 files, 4.0 MiB/4.0 MiB") || !strings.Contains(p, "ETA 0s, 1 failed") {
+		t.Error("progress not match", p)
+	}
+	if sum := st.Summary(); !strings.HasPrefix(sum, "1 files, 1.0 MiB uploaded") || !strings.Contains(sum, "failed: b: boom") {
+		t.Error("summary not match", sum)
+	}
+}
+
+func TestStatsSkipped(t *testing.T) {
+	st := newStats()
+	st.found(3 << 30)
+	st.found(1 << 20)
+	st.scanDone()
+	st.done(result{path: "a", size: 3 << 30, skipped: true})
+	// skipped bytes are done but never uploaded, as if nothing was sent
+	if p := st.Progress(); !strings.HasPrefix(p, "1/2 files, 3.0 GiB/3.0 GiB") || !strings.Contains(p, "0 B/s, ETA -") {
+		t.Error("progress not match", p)
+	}
+	st.done(result{path: "b", size: 1 << 20})
+	if sum := st.Summary(); !strings.HasPrefix(sum, "1 files, 1.0 MiB uploaded") || !strings.Contains(sum, "1 unchanged skipped") {
+		t.Error("summary not match", sum)
+	}
+}
+
+func TestManifest(t *testing.T) {
+	dir := tempDir(t, map[string]string{"a.txt": "hello", "b.txt": "world"})
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"manifest.jsonl", "manifest.csv"} {
+		path := filepath.Join(dir, name)
+		m, err := openManifest(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := fakeweed.New(nil)
+		server, recursive, concurrency, progressInterval = s.URL, false, 2, 0
+		setup()
+		run([]string{filepath.Join(dir, "a.txt"), filepath.Join(dir, "b.txt"), filepath.Join(dir, "c.txt")}, m, nil)
+		s.Close()
+		// flushed before closing
+		entries, err := manifest.ReadFile(path)
+		m.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(entries) != 3 {
+			t.Fatal(name, "entries:", len(entries))
+		}
+		for _, e := range entries {
+			content := map[string]string{"a.txt": "hello", "b.txt": "world"}[filepath.Base(e.Path)]
+			if content == "" {
+				if e.Error == "" || e.Fid != "" {
+					t.Error(name, "missing file should fail", e)
+				}
+				continue
+			}
+			sum := sha256.Sum256([]byte(content))
+			if e.Fid == "" || e.Error != "" || e.Size != 5 || e.Mime != "text/plain" ||
+				e.Sha256 != hex.EncodeToString(sum[:]) || e.Time.IsZero() {
+				t.Error(name, "entry not match", e)
+			}
+		}
+	}
+}
+
+func TestResume(t *testing.T) {
+	dir := tempDir(t, map[string]string{"a.txt": "aaa", "b.txt": "bbb", "c.txt": "ccc", "d.txt": "ddd"})
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "manifest.jsonl")
+	s := fakeweed.New(nil)
+	defer s.Close()
+	server, recursive, concurrency, progressInterval, include = s.URL, true, 2, 0, "*.txt"
+	setup()
+	resume := func() *stats {
+		last, err := loadManifest(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, err := openManifest(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer m.Close()
+		m.resumed = true
+		return run([]string{dir}, m, last)
+	}
+
+	// first run fails on d.txt
+	s.FailUpload = "d.txt"
+	if st := resume(); st.doneFiles != 4 || len(st.failed) != 1 {
+		t.Fatal("first run:", st.Summary())
+	}
+	s.FailUpload = ""
+
+	// touch a, change b, keep c, retry d
+	future := time.Now().Add(time.Hour)
+	os.Chtimes(filepath.Join(dir, "a.txt"), future, future)
+	ioutil.WriteFile(filepath.Join(dir, "b.txt"), []byte("bbbb"), 0644)
+	uploads := len(s.Uploads)
+	st := resume()
+	if len(st.failed) != 0 || st.changed != 1 {
+		t.Error("second run:", st.Summary())
+	}
+	if st.skipped != 2 || len(s.Uploads)-uploads != 2 {
+		t.Error("only b and d should be uploaded:", len(s.Uploads)-uploads, st.Summary())
+	}
+
+	// nothing changed since
+	uploads = len(s.Uploads)
+	if st := resume(); st.skipped != 4 || len(s.Uploads) != uploads {
+		t.Error("third run:", st.Summary())
+	}
+	last, _ := loadManifest(path)
+	b := last[filepath.Join(dir, "b.txt")]
+	if b == nil || b.Size != 4 || s.Files[b.Fid] != "bbbb" {
+		t.Error("b.txt not uploaded again", b)
+	}
+
+	// resumed to another manifest, which has the skipped files too
+	other := filepath.Join(dir, "other.jsonl")
+	m, err := openManifest(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	run([]string{dir}, m, last)
+	m.Close()
+	entries, err := loadManifest(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 4 || entries[b.Path].Fid != b.Fid || entries[b.Path].Sha256 != b.Sha256 {
+		t.Error("skipped files not in the other manifest:", entries)
+	}
+}
+
+// relative paths of uploaded files under dir, sorted
+func uploadedPaths(t *testing.T, dir string) []string {
+	paths := []string{}
+	for _, path := range fmap {
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, filepath.ToSlash(rel))
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func TestWalkOnce(t *testing.T) {
+	dir := tempDir(t, map[string]string{"a": "a", "b/c": "c", "b/d/e": "e", "b/d/f/g": "g"})
+	defer os.RemoveAll(dir)
+	s := upload(t, func() {}, dir)
+	defer s.Close()
+	if len(s.Uploads) != 4 {
+		t.Error("nested files uploaded more than once:", len(s.Uploads))
+	}
+	if got := strings.Join(uploadedPaths(t, dir), " "); got != "a b/c b/d/e b/d/f/g" {
+		t.Error("uploaded:", got)
+	}
+}
+
+func TestHiddenAndMaxDepth(t *testing.T) {
+	dir := tempDir(t, map[string]string{"a": "a", ".b": "b", ".c/d": "d", "e/f": "f", "e/g/h": "h"})
+	defer os.RemoveAll(dir)
+	for _, c := range []struct {
+		hidden   bool
+		maxDepth int
+		want     string
+	}{
+		{true, -1, ".b .c/d a e/f e/g/h"},
+		{false, -1, "a e/f e/g/h"},
+		{false, 0, "a"},
+		{false, 1, "a e/f"},
+	} {
+		s := upload(t, func() {
+			hidden, maxDepth = c.hidden, c.maxDepth
+		}, dir)
+		s.Close()
+		if got := strings.Join(uploadedPaths(t, dir), " "); got != c.want {
+			t.Errorf("hidden:%v maxDepth:%d uploaded: %s, want %s", c.hidden, c.maxDepth, got, c.want)
+		}
+	}
+}
+
+func TestSymlinks(t *testing.T) {
+	dir := tempDir(t, map[string]string{"a/b": "b", "c": "c"})
+	defer os.RemoveAll(dir)
+	if err := os.Symlink(filepath.Join(dir, "c"), filepath.Join(dir, "a", "c")); err != nil {
+		t.Skip("symlink not supported:", err)
+	}
+	os.Symlink(filepath.Join(dir, "a"), filepath.Join(dir, "d"))
+	// loop
+	os.Symlink(dir, filepath.Join(dir, "a", "loop"))
+
+	s := upload(t, func() { symlinks = symlinkSkip }, dir)
+	s.Close()
+	if got := strings.Join(uploadedPaths(t, dir), " "); got != "a/b c" {
+		t.Error("skip symlinks uploaded:", got)
+	}
+	s = upload(t, func() { symlinks = symlinkFollow }, dir)
+	s.Close()
+	// files are uploaded under the first path walked to them
+	if got := strings.Join(uploadedPaths(t, dir), " "); got != "a/b a/c c" {
+		t.Error("follow symlinks uploaded:", got)
+	}
+}
+
+func TestWeedIgnore(t *testing.T) {
+	dir := tempDir(t, map[string]string{
+		ignoreFile:          "# comment\n*.log\n!keep.log\nbuild/\n/top.txt\ndocs/**/*.tmp\n",
+		"a.log":             "a",
+		"keep.log":          "k",
+		"top.txt":           "t",
+		"build/x":           "x",
+		"sub/top.txt":       "t",
+		"sub/b.log":         "b",
+		"sub/build":         "file, not a directory",
+		"docs/c.tmp":        "c",
+		"docs/x/y/d.tmp":    "d",
+		"docs/e.txt":        "e",
+		"sub/" + ignoreFile: "*.txt\n!b.log\n",
+	})
+	defer os.RemoveAll(dir)
+	s := upload(t, func() {}, dir)
+	defer s.Close()
+	want := "docs/e.txt keep.log sub/b.log sub/build"
+	if got := strings.Join(uploadedPaths(t, dir), " "); got != want {
+		t.Errorf("uploaded: %s, want %s", got, want)
+	}
+}
+
+func TestIgnoreRule(t *testing.T) {
+	for _, c := range []struct {
+		pattern, path string
+		isDir, match  bool
+	}{
+		{"*.log", "a.log", false, true},
+		{"*.log", "x/y/a.log", false, true},
+		{"/a.log", "x/a.log", false, false},
+		{"x/*.log", "x/a.log", false, true},
+		{"x/*.log", "x/y/a.log", false, false},
+		{"**/y", "x/y", true, true},
+		{"x/**", "x/y/z", false, true},
+		{"a?[0-9]", "ab1", false, true},
+		{"a[!0-9]", "a1", false, false},
+		{"tmp/", "tmp", false, false},
+		{"tmp/", "tmp", true, true},
+		{"\\#a", "#a", false, true},
+	} {
+		rule := parseIgnoreRule(c.pattern)
+		if rule == nil {
+			t.Fatal("invalid pattern", c.pattern)
+		}
+		match := rule.re.MatchString(c.path) && (!rule.dirOnly || c.isDir)
+		if match != c.match {
+			t.Errorf("%q match %q: %v", c.pattern, c.path, match)
+		}
+	}
+	for _, line := range []string{"", "  ", "# comment"} {
+		if parseIgnoreRule(line) != nil {
+			t.Errorf("%q should be ignored", line)
+		}
+	}
+}
+
+func TestFiler(t *testing.T) {
+	dir := tempDir(t, map[string]string{"a.txt": "a", "b/c": "c", "b/d/e": "e", "f #1?/50% off.txt": "f"})
+	defer os.RemoveAll(dir)
+	s := upload(t, func() {
+		filerUrl, dest = strings.TrimPrefix(server, "http://"), "/backup"
+	}, dir, filepath.Join(dir, "a.txt"))
+	defer s.Close()
+	if len(s.Assigns) != 0 {
+		t.Error("filer uploads should not assign fids:", len(s.Assigns))
+	}
+	want := map[string]string{"backup/a.txt": "a", "backup/b/c": "c", "backup/b/d/e": "e", "backup/f #1?/50% off.txt": "f"}
+	if len(s.Files) != len(want) {
+		t.Error("uploaded:", s.Files)
+	}
+	for path, content := range want {
+		if s.Files[path] != content {
+			t.Errorf("%s uploaded: %q, want %q", path, s.Files[path], content)
+		}
+	}
+	if fmap["/backup/b/c"] != filepath.Join(dir, "b", "c") {
+		t.Error("filer paths not in fmap:", fmap)
+	}
+	if s.Uploads[0].Header.Get("Content-Type") == "" || s.Uploads[0].URL.Path[0] != '/' {
+		t.Error("upload request:", s.Uploads[0].URL)
+	}
+
+	// skip existing
+	n := len(s.Uploads)
+	delete(s.Files, "backup/b/c")
+	existing = existingSkip
+	if err := setup(); err != nil {
+		t.Fatal(err)
+	}
+	st := run([]string{dir}, nil, nil)
+	if len(st.failed) > 0 || st.skipped != 3 {
+		t.Error(st.Summary())
+	}
+	if len(s.Uploads) != n+1 || s.Files["backup/b/c"] != "c" {
+		t.Error("only missing files should be uploaded:", len(s.Uploads)-n)
+	}
+
+	// resume from a run uploading fids
+	abs, _ := filepath.Abs(filepath.Join(dir, "a.txt"))
+	info, _ := os.Stat(abs)
+	last := map[string]*manifest.Entry{abs: {Path: abs, Fid: "3,0112345678", Size: info.Size(), Mtime: info.ModTime()}}
+	delete(s.Files, "backup/a.txt")
+	existing = existingOverwrite
+	setup()
+	if st = run([]string{filepath.Join(dir, "a.txt")}, nil, last); st.skipped != 0 || s.Files["backup/a.txt"] != "a" {
+		t.Error("file uploaded as a fid should be uploaded to the filer:", st.Summary())
+	}
+	last[abs].Dest = "/backup/a.txt"
+	if st = run([]string{filepath.Join(dir, "a.txt")}, nil, last); st.skipped != 1 {
+		t.Error("file uploaded to the filer should be skipped:", st.Summary())
+	}
+
+	existing = "keep"
+	if err := setup(); err == nil {
+		t.Error("invalid -existing should fail")
+	}
+}
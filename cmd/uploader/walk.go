@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// policies of -symlinks
+const (
+	symlinkSkip   = "skip"
+	symlinkFollow = "follow"
+)
+
+// walk dirPath in a single pass and enqueue files to upload
+func uploadDirectory(dirPath string, enqueue func(path string, info os.FileInfo)) error {
+	if !recursive {
+		log.Println(dirPath, "is a directory")
+		return nil
+	}
+	if symlinks != symlinkSkip && symlinks != symlinkFollow {
+		return fmt.Errorf("invalid -symlinks %q, should be %s or %s", symlinks, symlinkFollow, symlinkSkip)
+	}
+	if debug {
+		log.Println("Uploading directory:", dirPath, "...")
+	}
+	w := &walker{enqueue: enqueue, ignores: newIgnores(), visited: map[string]bool{}}
+	return w.walk(dirPath, 0)
+}
+
+type walker struct {
+	enqueue func(path string, info os.FileInfo)
+	ignores *ignores
+	// real paths of directories walked, to stop symlink loops
+	visited map[string]bool
+}
+
+// walk root, which is depth levels below the directory to upload
+func (w *walker) walk(root string, depth int) error {
+	// WalkDir doesn't walk into root if it's a symlink without trailing slash
+	if info, err := os.Lstat(root); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		root += string(filepath.Separator)
+	}
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root {
+				return err
+			}
+			log.Printf("Walking %s error:%s", path, err.Error())
+			return nil
+		}
+		level := depth
+		if path != root {
+			rel, _ := filepath.Rel(root, path)
+			level += strings.Count(rel, string(filepath.Separator)) + 1
+		}
+
+		if d.IsDir() {
+			if path != root && w.skip(path, d.Name(), true) {
+				return filepath.SkipDir
+			}
+			if maxDepth >= 0 && level > maxDepth {
+				return filepath.SkipDir
+			}
+			if symlinks == symlinkFollow {
+				real, err := filepath.EvalSymlinks(path)
+				if err == nil {
+					if w.visited[real] {
+						if debug {
+							log.Println("\t", path, "skipped, already walked")
+						}
+						return filepath.SkipDir
+					}
+					w.visited[real] = true
+				}
+			}
+			if err := w.ignores.load(path); err != nil {
+				log.Printf("Reading %s error:%s", filepath.Join(path, ignoreFile), err.Error())
+			}
+			return nil
+		}
+
+		if d.Name() == ignoreFile {
+			return nil
+		}
+		if d.Type()&fs.ModeSymlink != 0 {
+			if symlinks == symlinkSkip {
+				if debug {
+					log.Println("\t", path, "skipped, symlink")
+				}
+				return nil
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				log.Printf("Following %s error:%s", path, err.Error())
+				return nil
+			}
+			if info.IsDir() {
+				if !w.skip(path, d.Name(), true) && (maxDepth < 0 || level <= maxDepth) {
+					if err := w.walk(path, level); err != nil {
+						log.Printf("Walking %s error:%s", path, err.Error())
+					}
+				}
+				return nil
+			}
+			if !w.skip(path, d.Name(), false) {
+				w.file(path, info)
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || w.skip(path, d.Name(), false) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			log.Printf("Walking %s error:%s", path, err.Error())
+			return nil
+		}
+		w.file(path, info)
+		return nil
+	})
+}
+
+// is path skipped for being hidden or ignored by .weedignore
+func (w *walker) skip(path, name string, isDir bool) bool {
+	if !hidden && strings.HasPrefix(name, ".") {
+		if debug {
+			log.Println("\t", path, "skipped, hidden")
+		}
+		return true
+	}
+	if w.ignores.ignored(path, isDir) {
+		if debug {
+			log.Println("\t", path, "skipped, ignored")
+		}
+		return true
+	}
+	return false
+}
+
+func (w *walker) file(path string, info os.FileInfo) {
+	if !included(path) {
+		if debug {
+			log.Println("\t", path, "skipped")
+		}
+		return
+	}
+	w.enqueue(path, info)
+}
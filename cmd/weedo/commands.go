@@ -0,0 +1,436 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Archs/weedo"
+	"github.com/Archs/weedo/internal/cliutil"
+)
+
+func init() {
+	commands = []*command{
+		{"put", "[-dest dir] [-name name] file...", "upload files, or stdin for -, as fids or into the filer under -dest", put},
+		{"get", "[-o file] fid|path", "download a fid or filer path to stdout or a file", get},
+		{"rm", "fid|path...", "delete fids or filer paths", rm},
+		{"ls", "[dir]", "list a filer directory", ls},
+		{"stat", "fid|path...", "show size, mime, etag and headers of files", stat},
+		{"status", "", "show master topology and volume stats", status},
+		{"grow", "[-count n] [-dataCenter dc]", "pre-allocate volumes of -collection and -replication", grow},
+		{"gc", "[-threshold ratio]", "vacuum volumes with garbage over the threshold", gc},
+		{"lookup", "volumeId|fid...", "show locations of volumes", lookup},
+		{"scrub", "[-concurrency n] manifest", "re-read fids of an uploader manifest, reporting corrupt or missing files", scrub},
+	}
+}
+
+// paths in filer start with a slash, the others are fids
+func isPath(target string) bool {
+	return strings.HasPrefix(target, "/")
+}
+
+func open(target string) (io.ReadCloser, *weedo.FileInfo, error) {
+	if isPath(target) {
+		return client.Filer(filerUrl).Get(target)
+	}
+	return client.Get(target)
+}
+
+type putResult struct {
+	Path string `json:"path"`
+	Fid  string `json:"fid,omitempty"`
+	Dest string `json:"dest,omitempty"` // path in filer
+	Size int64  `json:"size"`
+}
+
+type putResults []*putResult
+
+func (rs putResults) String() string {
+	b := bytes.Buffer{}
+	for _, r := range rs {
+		target := r.Fid
+		if r.Dest != "" {
+			target = r.Dest
+		}
+		fmt.Fprintf(&b, "%s\t%s\t%s\n", target, cliutil.HumanBytes(r.Size), r.Path)
+	}
+	return b.String()
+}
+
+func put(args []string) (interface{}, error) {
+	fs := findCommand("put").flags()
+	dest := fs.String("dest", "", "filer directory to upload into")
+	name := fs.String("name", "", "file name of stdin")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return nil, errors.New("no files specified")
+	}
+	results := putResults{}
+	for _, fpath := range fs.Args() {
+		r, err := putFile(fpath, *dest, *name)
+		if err != nil {
+			return results, fmt.Errorf("%s: %s", fpath, err)
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+func putFile(fpath, dest, name string) (r *putResult, err error) {
+	r = &putResult{Path: fpath}
+	var file io.Reader = os.Stdin
+	if fpath == "-" {
+		if name == "" {
+			name = "stdin"
+		}
+	} else {
+		f, err := os.Open(fpath)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		file = f
+		name = filepath.Base(fpath)
+	}
+	counter := &countingReader{r: file}
+	mimeType := mime.TypeByExtension(filepath.Ext(name))
+	if dest != "" {
+		r.Dest = path.Join("/", dest, name)
+		err = client.Filer(filerUrl).UploadWithOption(r.Dest, mimeType, counter, opt)
+		r.Size = counter.n
+	} else {
+		r.Fid, r.Size, err = client.AssignUploadWithOption(name, mimeType, counter, opt)
+	}
+	return
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+type getResult struct {
+	Target string `json:"target"`
+	File   string `json:"file"`
+	Size   int64  `json:"size"`
+}
+
+func (r *getResult) String() string {
+	return fmt.Sprintf("%s => %s (%s)", r.Target, r.File, cliutil.HumanBytes(r.Size))
+}
+
+func get(args []string) (interface{}, error) {
+	fs := findCommand("get").flags()
+	out := fs.String("o", "", "file or directory to save to, stdout if empty")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return nil, errors.New("one fid or path expected")
+	}
+	target := fs.Arg(0)
+	body, info, err := open(target)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	if *out == "" || *out == "-" {
+		_, err = io.Copy(stdout, body)
+		return nil, err
+	}
+
+	fpath := *out
+	if fi, err := os.Stat(fpath); err == nil && fi.IsDir() {
+		name := info.Name
+		if name == "" {
+			name = strings.Replace(target, ",", "_", -1)
+		}
+		fpath = filepath.Join(fpath, filepath.Base(name))
+	}
+	file, err := os.Create(fpath)
+	if err != nil {
+		return nil, err
+	}
+	n, err := io.Copy(file, body)
+	if e := file.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &getResult{Target: target, File: fpath, Size: n}, nil
+}
+
+type rmResult struct {
+	Target string `json:"target"`
+	Error  string `json:"error,omitempty"`
+}
+
+type rmResults []*rmResult
+
+func (rs rmResults) String() string {
+	b := bytes.Buffer{}
+	for _, r := range rs {
+		if r.Error != "" {
+			fmt.Fprintf(&b, "%s\tfailed: %s\n", r.Target, r.Error)
+		} else {
+			fmt.Fprintf(&b, "%s\tdeleted\n", r.Target)
+		}
+	}
+	return b.String()
+}
+
+func rm(args []string) (interface{}, error) {
+	fs := findCommand("rm").flags()
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return nil, errors.New("no fids or paths specified")
+	}
+	results := make(rmResults, fs.NArg())
+	fids, idx := []string{}, []int{}
+	for i, target := range fs.Args() {
+		results[i] = &rmResult{Target: target}
+		if isPath(target) {
+			if err := client.Filer(filerUrl).Delete(target); err != nil {
+				results[i].Error = err.Error()
+			}
+			continue
+		}
+		fids = append(fids, target)
+		idx = append(idx, i)
+	}
+	failed := 0
+	for i, r := range client.DeleteMany(fids) {
+		results[idx[i]].Error = r.Error
+	}
+	for _, r := range results {
+		if r.Error != "" {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return results, fmt.Errorf("%d of %d not deleted", failed, len(results))
+	}
+	return results, nil
+}
+
+func ls(args []string) (interface{}, error) {
+	fs := findCommand("ls").flags()
+	fs.Parse(args)
+	dir := "/"
+	if fs.NArg() > 0 {
+		dir = fs.Arg(0)
+	}
+	d, err := client.Filer(filerUrl).Dir(dir)
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+type statResult struct {
+	Target string `json:"target"`
+	*weedo.FileInfo
+}
+
+type statResults []*statResult
+
+func (rs statResults) String() string {
+	b := bytes.Buffer{}
+	for _, r := range rs {
+		fmt.Fprintln(&b, r.Target)
+		fmt.Fprintf(&b, "  Name:          %s\n", r.Name)
+		fmt.Fprintf(&b, "  Size:          %d (%s)\n", r.Size, cliutil.HumanBytes(r.Size))
+		fmt.Fprintf(&b, "  MimeType:      %s\n", r.MimeType)
+		fmt.Fprintf(&b, "  ETag:          %s\n", r.ETag)
+		if !r.LastModified.IsZero() {
+			fmt.Fprintf(&b, "  Last-Modified: %s\n", r.LastModified.Format(time.RFC1123))
+		}
+		if !r.Ttl.IsEmpty() {
+			fmt.Fprintf(&b, "  TTL:           %s\n", r.Ttl)
+		}
+		keys := []string{}
+		for k := range r.Pairs {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(&b, "  %s: %s\n", k, r.Pairs[k])
+		}
+	}
+	return b.String()
+}
+
+func stat(args []string) (interface{}, error) {
+	fs := findCommand("stat").flags()
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return nil, errors.New("no fids or paths specified")
+	}
+	results := statResults{}
+	for _, target := range fs.Args() {
+		var info *weedo.FileInfo
+		var err error
+		if isPath(target) {
+			info, err = client.Filer(filerUrl).Head(target)
+		} else {
+			info, err = client.Head(target)
+		}
+		if err != nil {
+			return results, fmt.Errorf("%s: %s", target, err)
+		}
+		results = append(results, &statResult{Target: target, FileInfo: info})
+	}
+	return results, nil
+}
+
+type statusResult struct {
+	Master string `json:"master"`
+	*weedo.SystemStatus
+	// status of volume servers by url
+	Volumes map[string]*weedo.VolumeStatus `json:"volumes"`
+}
+
+func (r *statusResult) String() string {
+	b := bytes.Buffer{}
+	t := r.Topology
+	fmt.Fprintf(&b, "master %s version %s, %d of %d volumes free\n", r.Master, r.Version, t.Free, t.Max)
+	for _, dc := range t.DataCenters {
+		fmt.Fprintf(&b, "DataCenter %s, %d of %d free\n", dc.Id, dc.Free, dc.Max)
+		for _, rack := range dc.Racks {
+			fmt.Fprintf(&b, "  Rack %s, %d of %d free\n", rack.Id, rack.Free, rack.Max)
+			for _, node := range rack.DataNodes {
+				fmt.Fprintf(&b, "    %s, %d volumes, %d of %d free\n", node.Url, node.Volumes, node.Free, node.Max)
+				vs := r.Volumes[node.Url]
+				if vs == nil {
+					continue
+				}
+				if vs.Error != "" {
+					fmt.Fprintf(&b, "      error: %s\n", vs.Error)
+				}
+				for _, v := range vs.Volumes {
+					fmt.Fprintf(&b, "      volume %d: %d files %s, %d deleted %s",
+						v.Id, v.FileCount, cliutil.HumanBytes(int64(v.Size)), v.DeleteCount, cliutil.HumanBytes(int64(v.DeletedByteCount)))
+					if v.Collection != "" {
+						fmt.Fprintf(&b, ", collection %s", v.Collection)
+					}
+					fmt.Fprintf(&b, ", replication %s", v.RepType)
+					if !v.Ttl.IsEmpty() {
+						fmt.Fprintf(&b, ", ttl %s", v.Ttl)
+					}
+					if v.ReadOnly {
+						b.WriteString(", read only")
+					}
+					b.WriteString("\n")
+				}
+			}
+		}
+	}
+	for _, l := range t.Layouts {
+		fmt.Fprintf(&b, "writable volumes of collection %q replication %s ttl %q: %v\n",
+			l.Collection, l.Replication, l.Ttl.String(), l.Writables)
+	}
+	return b.String()
+}
+
+func status(args []string) (interface{}, error) {
+	fs := findCommand("status").flags()
+	fs.Parse(args)
+	st, err := client.Master().SystemStatus()
+	if err != nil {
+		return nil, err
+	}
+	r := &statusResult{Master: client.Master().Url, SystemStatus: st, Volumes: map[string]*weedo.VolumeStatus{}}
+	for _, dc := range st.Topology.DataCenters {
+		for _, rack := range dc.Racks {
+			for _, node := range rack.DataNodes {
+				vs, err := weedo.NewVolume(node.Url, node.PublicUrl).VolumeStatus()
+				if err != nil {
+					vs = &weedo.VolumeStatus{Error: err.Error()}
+				}
+				r.Volumes[node.Url] = vs
+			}
+		}
+	}
+	return r, nil
+}
+
+type message struct {
+	Message string `json:"message"`
+}
+
+func (m message) String() string {
+	return m.Message
+}
+
+func grow(args []string) (interface{}, error) {
+	fs := findCommand("grow").flags()
+	count := fs.Int("count", 1, "number of volumes to grow")
+	dataCenter := fs.String("dataCenter", "", "data center of the volumes")
+	fs.Parse(args)
+	if err := client.Master().Grow(*count, collection, replication, *dataCenter); err != nil {
+		return nil, err
+	}
+	return message{fmt.Sprintf("%d volumes grown", *count)}, nil
+}
+
+func gc(args []string) (interface{}, error) {
+	fs := findCommand("gc").flags()
+	threshold := fs.Float64("threshold", 0.3, "ratio of garbage to vacuum a volume")
+	fs.Parse(args)
+	if err := client.Master().GC(*threshold); err != nil {
+		return nil, err
+	}
+	return message{fmt.Sprintf("volumes with garbage over %g vacuumed", *threshold)}, nil
+}
+
+type lookupResult struct {
+	VolumeId  string          `json:"volumeId"`
+	Locations []*weedo.Volume `json:"locations"`
+}
+
+type lookupResults []*lookupResult
+
+func (rs lookupResults) String() string {
+	b := bytes.Buffer{}
+	for _, r := range rs {
+		for _, v := range r.Locations {
+			fmt.Fprintf(&b, "%s\t%s\t%s\n", r.VolumeId, v.Url, v.PublicUrl)
+		}
+	}
+	return b.String()
+}
+
+func lookup(args []string) (interface{}, error) {
+	fs := findCommand("lookup").flags()
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return nil, errors.New("no volume ids specified")
+	}
+	results := lookupResults{}
+	for _, id := range fs.Args() {
+		vols, err := client.Master().Lookup(id, collection)
+		if err != nil {
+			return results, fmt.Errorf("%s: %s", id, err)
+		}
+		results = append(results, &lookupResult{VolumeId: id, Locations: vols})
+	}
+	return results, nil
+}
@@ -0,0 +1,179 @@
+// Command line tool of SeaweedFS
+//
+//	weedo [flags] command [arguments]
+//
+// Run "weedo help" for the commands.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/Archs/weedo"
+)
+
+var (
+	master      string
+	filerUrl    string
+	jsonOut     bool
+	collection  string
+	replication string
+	ttl         string
+	secret      string
+)
+
+// seconds before jwt signed by -secure.secret expire, the same as seaweedfs
+const jwtExpiresAfterSec = 10
+
+var (
+	client *weedo.Client
+	opt    *weedo.UploadOption
+	// output of commands
+	stdout io.Writer = os.Stdout
+)
+
+type command struct {
+	name  string
+	args  string // usage of arguments
+	short string
+	// run with the arguments after the command name, the returned value is
+	// printed by output
+	run func(args []string) (interface{}, error)
+}
+
+var commands []*command
+
+func findCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+// flags of command, -h prints its usage
+func (cmd *command) flags() *flag.FlagSet {
+	fs := flag.NewFlagSet(cmd.name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: weedo %s %s\n\n%s\n", cmd.name, cmd.args, cmd.short)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// create client and upload option from flags
+func setup() (err error) {
+	client = weedo.NewClient(master)
+	if secret != "" {
+		client.SetJwtSigningKey(secret, "", jwtExpiresAfterSec)
+	}
+	opt = &weedo.UploadOption{
+		Collection:  collection,
+		Replication: replication,
+	}
+	opt.Ttl, err = weedo.ParseTTL(ttl)
+	return
+}
+
+// print v as JSON with -json, or in human readable form
+func output(v interface{}) error {
+	if v == nil {
+		return nil
+	}
+	if jsonOut {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+	s := fmt.Sprint(v)
+	if s == "" {
+		return nil
+	}
+	if !strings.HasSuffix(s, "\n") {
+		s += "\n"
+	}
+	_, err := io.WriteString(stdout, s)
+	return err
+}
+
+// run command by args, returns the exit code
+func run(args []string) int {
+	if len(args) == 0 {
+		usage()
+		return 2
+	}
+	if args[0] == "help" || args[0] == "-h" {
+		if len(args) > 1 && findCommand(args[1]) != nil {
+			findCommand(args[1]).flags().Usage()
+		} else {
+			usage()
+		}
+		return 0
+	}
+	cmd := findCommand(args[0])
+	if cmd == nil {
+		fmt.Fprintln(os.Stderr, "weedo: unknown command", args[0])
+		usage()
+		return 2
+	}
+	if err := setup(); err != nil {
+		log.Println(err)
+		return 2
+	}
+	// results done are printed even if the command fails
+	v, err := cmd.run(args[1:])
+	if e := output(v); err == nil {
+		err = e
+	}
+	if err != nil {
+		if jsonOut {
+			output(struct {
+				Error string `json:"error"`
+			}{err.Error()})
+		} else {
+			log.Println(err)
+		}
+		return 1
+	}
+	return 0
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: weedo [flags] command [arguments]\n\ncommands:")
+	names := []string{}
+	for _, cmd := range commands {
+		names = append(names, cmd.name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		cmd := findCommand(name)
+		fmt.Fprintf(os.Stderr, "  %-7s %-24s %s\n", cmd.name, cmd.args, cmd.short)
+	}
+	fmt.Fprintln(os.Stderr, "\nflags:")
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	os.Exit(run(flag.Args()))
+}
+
+func init() {
+	flag.StringVar(&master, "master", "localhost:9333", "SeaweedFS master location")
+	flag.StringVar(&filerUrl, "filer", "localhost:8888", "SeaweedFS filer location, used by paths starting with /")
+	flag.BoolVar(&jsonOut, "json", false, "print results as JSON")
+	flag.StringVar(&collection, "collection", "", "collection name of put and grow")
+	flag.StringVar(&replication, "replication", "", "replication type of put and grow, e.g. 001")
+	flag.StringVar(&ttl, "ttl", "", "time to live of put, e.g.: 1m, 1h, 1d, 1M, 1y")
+	flag.StringVar(&secret, "secure.secret", "", "secret to sign Json Web Token(JWT)")
+	log.SetFlags(0)
+	log.SetPrefix("weedo: ")
+}
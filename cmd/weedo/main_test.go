@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Archs/weedo/internal/fakeweed"
+)
+
+// run weedo with args against s, returns the output and exit code
+func runWeedo(s *fakeweed.Server, args ...string) (string, int) {
+	master, filerUrl, jsonOut = s.URL, s.URL, false
+	collection, replication, ttl, secret = "", "", "", ""
+	if err := flag.CommandLine.Parse(args); err != nil {
+		return err.Error(), 2
+	}
+	args = flag.Args()
+	b := new(bytes.Buffer)
+	stdout = b
+	defer func() { stdout = os.Stdout }()
+	code := run(args)
+	return b.String(), code
+}
+
+func tempFile(t *testing.T, content string) string {
+	dir, err := ioutil.TempDir("", "weedo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "a.txt")
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestPutGetRm(t *testing.T) {
+	s := fakeweed.New(nil)
+	defer s.Close()
+	path := tempFile(t, "hello")
+	defer os.RemoveAll(filepath.Dir(path))
+
+	out, code := runWeedo(s, "put", path)
+	fields := strings.Split(out, "\t")
+	if code != 0 || fields[0] != "3,0112345678" || fields[1] != "5 B" {
+		t.Fatalf("put: %d %q", code, out)
+	}
+	if out, code = runWeedo(s, "get", "3,0112345678"); code != 0 || out != "hello" {
+		t.Errorf("get: %d %q", code, out)
+	}
+	dir := filepath.Dir(path)
+	s.Names["3,0112345678"] = "3,0112345678.txt"
+	if out, code = runWeedo(s, "get", "-o", dir, "3,0112345678"); code != 0 {
+		t.Errorf("get -o: %d %q", code, out)
+	}
+	if b, err := ioutil.ReadFile(filepath.Join(dir, "3,0112345678.txt")); err != nil || string(b) != "hello" {
+		t.Error("get -o saved:", string(b), err)
+	}
+	if out, code = runWeedo(s, "rm", "3,0112345678", "3,0212345678"); code != 1 || !strings.Contains(out, "3,0112345678\tdeleted") {
+		t.Errorf("rm: %d %q", code, out)
+	}
+	if len(s.Files) != 0 {
+		t.Error("not deleted:", s.Files)
+	}
+}
+
+func TestFilerPaths(t *testing.T) {
+	s := fakeweed.New(nil)
+	defer s.Close()
+	path := tempFile(t, "hello")
+	defer os.RemoveAll(filepath.Dir(path))
+
+	if out, code := runWeedo(s, "put", "-dest", "/docs", path); code != 0 || !strings.HasPrefix(out, "/docs/a.txt\t5 B") {
+		t.Fatalf("put -dest: %d %q", code, out)
+	}
+	if s.Files["docs/a.txt"] != "hello" {
+		t.Error("filer upload:", s.Files)
+	}
+	if out, code := runWeedo(s, "ls", "/docs"); code != 0 || !strings.Contains(out, "  a.txt\n") {
+		t.Errorf("ls: %d %q", code, out)
+	}
+	down := fakeweed.New(nil)
+	down.Close()
+	if out, code := runWeedo(down, "ls", "/docs"); code != 1 || out != "" {
+		t.Errorf("ls of a filer down: %d %q", code, out)
+	}
+	// the error is the only json document
+	out, code := runWeedo(down, "-json", "ls", "/docs")
+	var e struct{ Error string }
+	if err := json.Unmarshal([]byte(out), &e); err != nil || code != 1 || e.Error == "" {
+		t.Errorf("ls -json of a filer down: %d %q", code, out)
+	}
+	if out, code := runWeedo(s, "get", "/docs/a.txt"); code != 0 || out != "hello" {
+		t.Errorf("get path: %d %q", code, out)
+	}
+	if out, code := runWeedo(s, "rm", "/docs/a.txt"); code != 0 || len(s.Files) != 0 {
+		t.Errorf("rm path: %d %q", code, out)
+	}
+}
+
+func TestStat(t *testing.T) {
+	s := fakeweed.New(nil)
+	defer s.Close()
+	s.Files["3,0112345678"] = "hello"
+	out, code := runWeedo(s, "stat", "3,0112345678")
+	if code != 0 || !strings.Contains(out, "ETag:          5d41402abc4b2a76b9719d911017c592") || !strings.Contains(out, "TTL:           3d") {
+		t.Errorf("stat: %d %q", code, out)
+	}
+
+	out, code = runWeedo(s, "-json", "stat", "3,0112345678", "3,0212345678")
+	var v []map[string]interface{}
+	dec := json.NewDecoder(strings.NewReader(out))
+	if err := dec.Decode(&v); err != nil || code != 1 {
+		t.Fatalf("stat -json: %d %q", code, out)
+	}
+	if len(v) != 1 || v[0]["target"] != "3,0112345678" || v[0]["Size"] != 5.0 || v[0]["ETag"] != "5d41402abc4b2a76b9719d911017c592" {
+		t.Error("stat -json:", v)
+	}
+	var e struct{ Error string }
+	if err := dec.Decode(&e); err != nil || !strings.HasPrefix(e.Error, "3,0212345678: ") {
+		t.Error("stat -json error:", e, err)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	s := fakeweed.New(nil)
+	defer s.Close()
+	out, code := runWeedo(s, "status")
+	for _, want := range []string{"version 0.77, 3 of 7 volumes free", "Rack rack1", "volume 3: 2 files 2.0 KiB", "ttl 3d", "[3 4]"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("status: %q not in %q", want, out)
+		}
+	}
+	if code != 0 {
+		t.Error("status code:", code)
+	}
+
+	out, code = runWeedo(s, "-json", "status")
+	v := struct {
+		Topology struct{ Max int }
+		Volumes  map[string]struct{ Volumes []struct{ FileCount int } }
+	}{}
+	if err := json.Unmarshal([]byte(out), &v); err != nil || code != 0 {
+		t.Fatalf("status -json: %d %q", code, out)
+	}
+	if v.Topology.Max != 7 || v.Volumes[strings.TrimPrefix(s.URL, "http://")].Volumes[0].FileCount != 2 {
+		t.Error("status -json:", v)
+	}
+}
+
+func TestLookupGrowGC(t *testing.T) {
+	s := fakeweed.New(nil)
+	defer s.Close()
+	out, code := runWeedo(s, "lookup", "3,0112345678")
+	if code != 0 || strings.Count(out, "\n") != 2 || !strings.Contains(out, "3,0112345678\thttp://b:8080\thttp://b\n") {
+		t.Errorf("lookup: %d %q", code, out)
+	}
+	if out, code = runWeedo(s, "lookup", "9"); code != 1 {
+		t.Errorf("lookup unknown: %d %q", code, out)
+	}
+	if out, code = runWeedo(s, "-collection", "pics", "grow", "-count", "2"); code != 0 || s.Grown != "collection=pics&count=2" {
+		t.Errorf("grow: %d %q %s", code, out, s.Grown)
+	}
+	if out, code = runWeedo(s, "gc"); code != 0 {
+		t.Errorf("gc: %d %q", code, out)
+	}
+	if _, code = runWeedo(s, "nope"); code != 2 {
+		t.Error("unknown command:", code)
+	}
+}
+
+func TestScrub(t *testing.T) {
+	s := fakeweed.New(nil)
+	defer s.Close()
+	s.Files["3,0112345678"] = "hello"
+	s.Files["3,0212345678"] = "corrupt"
+	path := tempFile(t, strings.Join([]string{
+		`{"path":"a.txt","fid":"3,0112345678","size":5,"sha256":"2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"}`,
+		`{"path":"b.txt","fid":"3,0212345678","size":5}`,
+		`{"path":"c.txt","fid":"3,0312345678","size":5}`,
+		`{"path":"d.txt","error":"upload failed"}`,
+	}, "\n"))
+	defer os.RemoveAll(filepath.Dir(path))
+
+	out, code := runWeedo(s, "scrub", path)
+	if code != 1 || !strings.Contains(out, "3,0212345678\tcorrupt\t") || !strings.Contains(out, "3,0312345678\tmissing\t") ||
+		!strings.HasSuffix(out, "3 files: 1 ok, 1 corrupt, 1 missing, 0 errors\n") {
+		t.Errorf("scrub: %d %q", code, out)
+	}
+	delete(s.Files, "3,0212345678")
+	ioutil.WriteFile(path, []byte("3,0112345678 md5:5d41402abc4b2a76b9719d911017c592\n"), 0644)
+	if out, code = runWeedo(s, "scrub", path); code != 0 {
+		t.Errorf("scrub: %d %q", code, out)
+	}
+	// paths after fids as read by downloader are no hashes
+	ioutil.WriteFile(path, []byte("3,0112345678 docs/hello.txt\n3,0112345678 md5:hello\n"), 0644)
+	if out, code = runWeedo(s, "scrub", path); code != 0 || !strings.HasSuffix(out, "2 files: 2 ok, 0 corrupt, 0 missing, 0 errors\n") {
+		t.Errorf("scrub: %d %q", code, out)
+	}
+}
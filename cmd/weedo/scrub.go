@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Archs/weedo"
+	"github.com/Archs/weedo/internal/manifest"
+)
+
+// Fids of manifest written by uploader, as JSON lines, CSV if path ends
+// with .csv, or lines of "fid [hash]". Fids failed to upload are skipped,
+// and text after fids not like a hash, such as paths, is ignored
+func readManifest(path string) ([]weedo.ScrubEntry, error) {
+	entries, err := manifest.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	scrubs := []weedo.ScrubEntry{}
+	for _, e := range entries {
+		if e.Fid == "" || e.Error != "" {
+			continue
+		}
+		se := weedo.ScrubEntry{Fid: e.Fid, Size: e.Size, Hash: e.Sha256}
+		if isHash(e.Text) {
+			se.Hash = e.Text
+		}
+		if e.Size == 0 && e.Sha256 == "" {
+			se.Size = -1
+		}
+		scrubs = append(scrubs, se)
+	}
+	return scrubs, nil
+}
+
+// is s md5 or sha256 in hex, optionally prefixed by "md5:" or "sha256:"
+func isHash(s string) bool {
+	s = strings.ToLower(s)
+	switch {
+	case strings.HasPrefix(s, "md5:"):
+		s = s[len("md5:"):]
+		if len(s) != 32 {
+			return false
+		}
+	case strings.HasPrefix(s, "sha256:"):
+		s = s[len("sha256:"):]
+		if len(s) != 64 {
+			return false
+		}
+	case len(s) != 32 && len(s) != 64:
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+type scrubResults []*weedo.ScrubResult
+
+// counts of results by status
+func (rs scrubResults) count() map[string]int {
+	n := map[string]int{}
+	for _, r := range rs {
+		n[r.Status]++
+	}
+	return n
+}
+
+// files not ok and the summary
+func (rs scrubResults) String() string {
+	b := bytes.Buffer{}
+	for _, r := range rs {
+		if r.Status != weedo.ScrubOk {
+			fmt.Fprintf(&b, "%s\t%s\t%s\n", r.Fid, r.Status, r.Error)
+		}
+	}
+	n := rs.count()
+	fmt.Fprintf(&b, "%d files: %d ok, %d corrupt, %d missing, %d errors\n", len(rs),
+		n[weedo.ScrubOk], n[weedo.ScrubCorrupt], n[weedo.ScrubMissing], n[weedo.ScrubError])
+	return b.String()
+}
+
+func scrub(args []string) (interface{}, error) {
+	fs := findCommand("scrub").flags()
+	concurrency := fs.Int("concurrency", 4, "number of files read in parallel")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return nil, errors.New("one manifest expected")
+	}
+	entries, err := readManifest(fs.Arg(0))
+	if err != nil {
+		return nil, err
+	}
+	results := scrubResults(client.Scrub(entries, *concurrency))
+	if bad := len(results) - results.count()[weedo.ScrubOk]; bad > 0 {
+		return results, fmt.Errorf("%d of %d files not ok", bad, len(results))
+	}
+	return results, nil
+}
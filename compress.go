@@ -0,0 +1,194 @@
+// compression of uploads
+package weedo
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Compressor of a content encoding
+type Compressor struct {
+	// level is the one of Compression, 0 for the default of the encoding
+	NewWriter func(w io.Writer, level int) (io.WriteCloser, error)
+	NewReader func(r io.Reader) (io.ReadCloser, error)
+}
+
+var (
+	compressorsMu sync.RWMutex
+	compressors   = map[string]Compressor{
+		"gzip": {
+			NewWriter: func(w io.Writer, level int) (io.WriteCloser, error) {
+				if level == 0 {
+					level = gzip.DefaultCompression
+				}
+				return gzip.NewWriterLevel(w, level)
+			},
+			NewReader: func(r io.Reader) (io.ReadCloser, error) {
+				return gzip.NewReader(r)
+			},
+		},
+	}
+)
+
+// Register compressor of a content encoding, for uploads by Compression and
+// reads of files stored with it. gzip is built in, zstd can be registered
+// with github.com/klauspost/compress/zstd for example:
+//
+//	weedo.RegisterCompressor("zstd", weedo.Compressor{
+//		NewWriter: func(w io.Writer, level int) (io.WriteCloser, error) {
+//			return zstd.NewWriter(w)
+//		},
+//		NewReader: func(r io.Reader) (io.ReadCloser, error) {
+//			d, err := zstd.NewReader(r)
+//			if err != nil {
+//				return nil, err
+//			}
+//			return d.IOReadCloser(), nil
+//		},
+//	})
+func RegisterCompressor(encoding string, c Compressor) {
+	compressorsMu.Lock()
+	compressors[strings.ToLower(encoding)] = c
+	compressorsMu.Unlock()
+}
+
+func compressor(encoding string) (Compressor, bool) {
+	compressorsMu.RLock()
+	defer compressorsMu.RUnlock()
+	c, ok := compressors[strings.ToLower(encoding)]
+	return c, ok
+}
+
+// Mime types compressed by default, patterns of path.Match
+var DefaultCompressibleTypes = []string{
+	"text/*",
+	"application/json",
+	"application/xml",
+	"application/javascript",
+	"application/x-javascript",
+	"application/x-yaml",
+	"image/svg+xml",
+	"*/*+json",
+	"*/*+xml",
+}
+
+// Compression of files on upload, see UploadOption.Compression
+type Compression struct {
+	Encoding string // content encoding, gzip or one registered by RegisterCompressor
+	Level    int    // compression level of the encoding, 0 for its default
+	MinSize  int64  // smaller files are not compressed
+	// mime types to compress as patterns of path.Match like "text/*",
+	// DefaultCompressibleTypes if nil
+	MimeTypes []string
+}
+
+// is a file of mimeType compressed
+func (c *Compression) compressible(mimeType string) bool {
+	mediaType, _, err := mime.ParseMediaType(mimeType)
+	if err != nil {
+		return false
+	}
+	patterns := c.MimeTypes
+	if patterns == nil {
+		patterns = DefaultCompressibleTypes
+	}
+	for _, p := range patterns {
+		if ok, _ := path.Match(strings.ToLower(p), mediaType); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// content of file compressed if it's compressible and not smaller than
+// MinSize, encoding is empty if it's not compressed. The caller must close
+// r to stop the compression if it's not read to the end
+func (c *Compression) compress(mimeType string, file io.Reader) (r io.ReadCloser, encoding string, err error) {
+	if c == nil || !c.compressible(mimeType) {
+		return ioutil.NopCloser(file), "", nil
+	}
+	comp, ok := compressor(c.Encoding)
+	if !ok {
+		return nil, "", errors.New("unknown content encoding " + c.Encoding)
+	}
+	if size, ok := readerSize(file); ok {
+		if size < c.MinSize {
+			return ioutil.NopCloser(file), "", nil
+		}
+	} else if c.MinSize > 0 {
+		buf := new(bytes.Buffer)
+		if _, err = io.CopyN(buf, file, c.MinSize); err == io.EOF {
+			return ioutil.NopCloser(buf), "", nil
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		file = io.MultiReader(buf, file)
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		w, err := comp.NewWriter(pw, c.Level)
+		if err == nil {
+			_, err = io.Copy(w, file)
+			if e := w.Close(); err == nil {
+				err = e
+			}
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr, strings.ToLower(c.Encoding), nil
+}
+
+// accept encodings of registered compressors on reads
+func acceptEncoding(h http.Header) {
+	compressorsMu.RLock()
+	encodings := make([]string, 0, len(compressors))
+	for e := range compressors {
+		encodings = append(encodings, e)
+	}
+	compressorsMu.RUnlock()
+	sort.Strings(encodings)
+	h.Set("Accept-Encoding", strings.Join(encodings, ", "))
+}
+
+// decompress body of resp by its content encoding if it's registered, as the
+// http transport does for gzip
+func decodeBody(resp *http.Response) error {
+	encoding := resp.Header.Get("Content-Encoding")
+	comp, ok := compressor(encoding)
+	if encoding == "" || !ok {
+		return nil
+	}
+	r, err := comp.NewReader(resp.Body)
+	if err != nil {
+		resp.Body.Close()
+		return err
+	}
+	resp.Body = &decodedBody{ReadCloser: r, body: resp.Body}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return nil
+}
+
+// decompressed body closing both the decompressor and the body
+type decodedBody struct {
+	io.ReadCloser
+	body io.ReadCloser
+}
+
+func (b *decodedBody) Close() error {
+	b.ReadCloser.Close()
+	return b.body.Close()
+}
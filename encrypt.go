@@ -0,0 +1,377 @@
+// client side encryption
+package weedo
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strconv"
+	"sync"
+)
+
+// Provider of key encryption keys, which wrap the data key of each object
+type KeyProvider interface {
+	// Wrap a new data key by the current key, keyId must be a valid
+	// http header value as it's sent with the upload
+	WrapKey(dataKey []byte) (keyId string, wrapped []byte, err error)
+	// Unwrap a data key wrapped by the key of keyId
+	UnwrapKey(keyId string, wrapped []byte) (dataKey []byte, err error)
+}
+
+var (
+	// File read by EncryptingClient was not uploaded by one
+	ErrNotEncrypted = errors.New("file is not encrypted")
+	// Wrong key, or content modified or truncated
+	ErrDecrypt = errors.New("decryption failed")
+)
+
+// KeyProvider with AES keys in memory
+type StaticKeyProvider struct {
+	mu      sync.RWMutex
+	current string
+	keys    map[string]cipher.AEAD
+}
+
+// Data keys are wrapped by key of keyId, a 16, 24 or 32 bytes AES key
+func NewStaticKeyProvider(keyId string, key []byte) (*StaticKeyProvider, error) {
+	p := &StaticKeyProvider{current: keyId, keys: make(map[string]cipher.AEAD)}
+	if err := p.AddKey(keyId, key); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Add a key to unwrap data keys only, such as keys rotated out
+func (p *StaticKeyProvider) AddKey(keyId string, key []byte) error {
+	aead, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	p.keys[keyId] = aead
+	p.mu.Unlock()
+	return nil
+}
+
+func (p *StaticKeyProvider) WrapKey(dataKey []byte) (string, []byte, error) {
+	p.mu.RLock()
+	aead := p.keys[p.current]
+	p.mu.RUnlock()
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", nil, err
+	}
+	return p.current, aead.Seal(nonce, nonce, dataKey, []byte(p.current)), nil
+}
+
+func (p *StaticKeyProvider) UnwrapKey(keyId string, wrapped []byte) ([]byte, error) {
+	p.mu.RLock()
+	aead, ok := p.keys[keyId]
+	p.mu.RUnlock()
+	if !ok {
+		return nil, errors.New("unknown key id " + keyId)
+	}
+	if len(wrapped) < aead.NonceSize() {
+		return nil, ErrDecrypt
+	}
+	n := aead.NonceSize()
+	dataKey, err := aead.Open(nil, wrapped[:n], wrapped[n:], []byte(keyId))
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return dataKey, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+const (
+	// Default plain text bytes encrypted in a chunk
+	DefaultEncryptChunkSize = 64 << 10
+	// Chunks are buffered by reads, larger ones are not decrypted
+	MaxEncryptChunkSize = 16 << 20
+)
+
+// metadata pairs of encrypted files
+const (
+	encAlg       = "AES256-GCM-STREAM"
+	encAlgPair   = "Enc-Alg"
+	encKeyIdPair = "Enc-Key-Id"
+	encKeyPair   = "Enc-Key" // data key wrapped by the key provider
+	encNoncePair = "Enc-Nonce"
+	encChunkPair = "Enc-Chunk"
+)
+
+// Client encrypting files before they are uploaded, and decrypting them on
+// reads. Each file is encrypted by its own data key with AES-GCM in chunks,
+// the data key wrapped by KeyProvider and the nonce are uploaded as metadata
+// pairs, see UploadOption.Pairs
+type EncryptingClient struct {
+	client    *Client
+	keys      KeyProvider
+	chunkSize int
+}
+
+func NewEncryptingClient(client *Client, keys KeyProvider) *EncryptingClient {
+	return &EncryptingClient{client: client, keys: keys, chunkSize: DefaultEncryptChunkSize}
+}
+
+// The underlying client, files uploaded by it are not encrypted
+func (c *EncryptingClient) Client() *Client {
+	return c.client
+}
+
+// Plain text bytes encrypted in a chunk up to MaxEncryptChunkSize, a chunk is
+// buffered in memory by uploads and reads. Must be called before the client
+// is shared
+func (c *EncryptingClient) SetChunkSize(size int) {
+	c.chunkSize = size
+}
+
+func (c *EncryptingClient) AssignUpload(filename, mimeType string, file io.Reader) (fid string, size int64, err error) {
+	return c.AssignUploadWithOption(filename, mimeType, file, nil)
+}
+
+// Client.AssignUploadWithOption with file encrypted, size is the plain
+// text size. opt.Verify checks the encrypted content
+func (c *EncryptingClient) AssignUploadWithOption(filename, mimeType string, file io.Reader, opt *UploadOption) (fid string, size int64, err error) {
+	if c.chunkSize <= 0 || c.chunkSize > MaxEncryptChunkSize {
+		return "", 0, errors.New("invalid encryption chunk size " + strconv.Itoa(c.chunkSize))
+	}
+	dataKey := make([]byte, 32)
+	nonce := make([]byte, 12)
+	if _, err = rand.Read(dataKey); err != nil {
+		return
+	}
+	if _, err = rand.Read(nonce); err != nil {
+		return
+	}
+	keyId, wrapped, err := c.keys.WrapKey(dataKey)
+	if err != nil {
+		return
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return
+	}
+
+	o := UploadOption{Pairs: make(map[string]string)}
+	if opt != nil {
+		o = *opt
+		o.Pairs = make(map[string]string)
+		for k, v := range opt.Pairs {
+			o.Pairs[k] = v
+		}
+	}
+	// encrypted content doesn't compress
+	o.Compression = nil
+	o.Pairs[encAlgPair] = encAlg
+	o.Pairs[encKeyIdPair] = keyId
+	o.Pairs[encKeyPair] = base64.StdEncoding.EncodeToString(wrapped)
+	o.Pairs[encNoncePair] = base64.StdEncoding.EncodeToString(nonce)
+	o.Pairs[encChunkPair] = strconv.Itoa(c.chunkSize)
+
+	r := &encryptingReader{r: bufio.NewReader(file), aead: aead, nonce: nonce, buf: make([]byte, c.chunkSize)}
+	fid, _, err = c.client.AssignUploadWithOption(filename, mimeType, r, &o)
+	return fid, r.n, err
+}
+
+// Client.Get decrypting the body, reading it to the end returns ErrDecrypt
+// instead of io.EOF if the content was modified. info.Size is the plain
+// text size, info.ETag is of the encrypted content. Files not uploaded by
+// an EncryptingClient return ErrNotEncrypted
+func (c *EncryptingClient) Get(fid string) (body io.ReadCloser, info *FileInfo, err error) {
+	body, info, err = c.client.Get(fid)
+	if err != nil {
+		return
+	}
+	r, err := c.decrypter(body, info)
+	if err != nil {
+		body.Close()
+		return nil, nil, err
+	}
+	return r, info, nil
+}
+
+func (c *EncryptingClient) Delete(fid string, count int) error {
+	return c.client.Delete(fid, count)
+}
+
+// decrypting reader of body by metadata pairs of info
+func (c *EncryptingClient) decrypter(body io.ReadCloser, info *FileInfo) (io.ReadCloser, error) {
+	pairs := info.Pairs
+	if pairs[encAlgPair] == "" {
+		return nil, ErrNotEncrypted
+	}
+	if pairs[encAlgPair] != encAlg {
+		return nil, errors.New("unknown encryption " + pairs[encAlgPair])
+	}
+	wrapped, err := base64.StdEncoding.DecodeString(pairs[encKeyPair])
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := base64.StdEncoding.DecodeString(pairs[encNoncePair])
+	if err != nil {
+		return nil, err
+	}
+	// the chunk size is authenticated by chunks, but only once it's allocated
+	chunkSize, err := strconv.Atoi(pairs[encChunkPair])
+	if err != nil || chunkSize <= 0 || chunkSize > MaxEncryptChunkSize {
+		return nil, errors.New("invalid encryption chunk size " + pairs[encChunkPair])
+	}
+	dataKey, err := c.keys.UnwrapKey(pairs[encKeyIdPair], wrapped)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return nil, ErrDecrypt
+	}
+	if info.Size >= 0 {
+		info.Size = plainSize(info.Size, chunkSize, aead.Overhead())
+	}
+	return &decryptingReader{
+		ReadCloser: body,
+		r:          bufio.NewReader(body),
+		aead:       aead,
+		nonce:      nonce,
+		buf:        make([]byte, chunkSize+aead.Overhead()),
+	}, nil
+}
+
+// plain text size of encrypted content of size
+func plainSize(size int64, chunkSize, overhead int) int64 {
+	sealed := int64(chunkSize + overhead)
+	chunks := (size + sealed - 1) / sealed
+	if chunks == 0 {
+		chunks = 1
+	}
+	return size - chunks*int64(overhead)
+}
+
+// nonce of the i-th chunk
+func chunkNonce(dst, nonce []byte, i uint32) []byte {
+	dst = append(dst[:0], nonce...)
+	n := len(dst) - 4
+	binary.BigEndian.PutUint32(dst[n:], binary.BigEndian.Uint32(dst[n:])^i)
+	return dst
+}
+
+// additional data of chunks binding the algorithm and chunk size of the
+// metadata pairs, the last chunk is sealed with a different one so that
+// truncated content can't be decrypted
+func chunkAD(chunkSize int, last bool) []byte {
+	ad := encAlg + "/" + strconv.Itoa(chunkSize)
+	if last {
+		return []byte(ad + "/last")
+	}
+	return []byte(ad)
+}
+
+// read a chunk of len(buf) bytes from r, last is true if r is at EOF after it
+func readChunk(r *bufio.Reader, buf []byte) (n int, last bool, err error) {
+	n, err = io.ReadFull(r, buf)
+	switch err {
+	case nil:
+		if _, err = r.Peek(1); err == io.EOF {
+			return n, true, nil
+		}
+		return n, false, err
+	case io.ErrUnexpectedEOF:
+		return n, true, nil
+	}
+	return
+}
+
+// encrypted content of r in chunks
+type encryptingReader struct {
+	r     *bufio.Reader
+	aead  cipher.AEAD
+	nonce []byte
+	buf   []byte // plain text of a chunk
+	chunk []byte // sealed chunk
+	out   []byte // rest of chunk not read yet
+	i     uint32
+	n     int64 // plain text bytes
+	done  bool
+}
+
+func (e *encryptingReader) Read(p []byte) (int, error) {
+	for len(e.out) == 0 {
+		if e.done {
+			return 0, io.EOF
+		}
+		n, last, err := readChunk(e.r, e.buf)
+		if err == io.EOF {
+			last, err = true, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		e.done = last
+		e.n += int64(n)
+		nonce := chunkNonce(nil, e.nonce, e.i)
+		e.chunk = e.aead.Seal(e.chunk[:0], nonce, e.buf[:n], chunkAD(len(e.buf), last))
+		e.out = e.chunk
+		e.i++
+	}
+	n := copy(p, e.out)
+	e.out = e.out[n:]
+	return n, nil
+}
+
+// decrypted content of body in chunks
+type decryptingReader struct {
+	io.ReadCloser
+	r     *bufio.Reader
+	aead  cipher.AEAD
+	nonce []byte
+	buf   []byte // sealed chunk
+	out   []byte // plain text not read yet
+	i     uint32
+	done  bool
+	err   error
+}
+
+func (d *decryptingReader) Read(p []byte) (int, error) {
+	for len(d.out) == 0 {
+		if d.err != nil {
+			return 0, d.err
+		}
+		if d.done {
+			return 0, io.EOF
+		}
+		n, last, err := readChunk(d.r, d.buf)
+		if err == io.EOF {
+			// the last chunk is missing
+			err = ErrDecrypt
+		}
+		if err != nil {
+			d.err = err
+			continue
+		}
+		d.done = last
+		nonce := chunkNonce(nil, d.nonce, d.i)
+		ad := chunkAD(len(d.buf)-d.aead.Overhead(), last)
+		if d.out, err = d.aead.Open(d.buf[:0], nonce, d.buf[:n], ad); err != nil {
+			d.err = ErrDecrypt
+		}
+		d.i++
+	}
+	n := copy(p, d.out)
+	d.out = d.out[n:]
+	return n, nil
+}
@@ -3,8 +3,12 @@ package weedo
 
 import (
 	"bytes"
+	"errors"
 	"io"
 	"net/http"
+	"net/url"
+	"path"
+	"strconv"
 	"strings"
 )
 
@@ -45,44 +49,147 @@ func NewFiler(url string) *Filer {
 	}
 }
 
+// Files listed by a request of Dir, the filer lists 100 by default
+const filerDirLimit = 100
+
+// List directory at pathname, the files are requested in pages until a page
+// comes back short
 func (f *Filer) Dir(pathname string) (*Dir, error) {
-	if !strings.HasPrefix(pathname, "/") {
-		pathname = "/" + pathname
-	}
 	if !strings.HasSuffix(pathname, "/") {
 		pathname = pathname + "/"
 	}
-	resp, err := http.Get(f.Url + pathname)
+	var dir *Dir
+	for last := ""; ; {
+		query := url.Values{"limit": {strconv.Itoa(filerDirLimit)}}
+		if last != "" {
+			query.Set("lastFileName", last)
+		}
+		page, err := f.dirPage(f.pathUrl(pathname) + "?" + query.Encode())
+		if err != nil {
+			return nil, err
+		}
+		if dir == nil {
+			// subdirectories are all listed by every page
+			dir = page
+		} else {
+			dir.Files = append(dir.Files, page.Files...)
+		}
+		if len(page.Files) < filerDirLimit {
+			return dir, nil
+		}
+		last = page.Files[len(page.Files)-1].Name
+	}
+}
+
+func (f *Filer) dirPage(url string) (*Dir, error) {
+	resp, err := get(url, nil)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
-	filerResp := new(Dir)
-	if err = decodeJson(resp.Body, filerResp); err != nil {
+	page := new(Dir)
+	if err = decodeJson(resp.Body, page); err != nil {
 		return nil, err
 	}
-	return filerResp, nil
+	return page, nil
 }
 
 func (f *Filer) Upload(pathname string, mimeType string, file io.Reader) error {
-	formData, contentType, err := makeFormData(pathname, mimeType, file)
+	return f.UploadWithOption(pathname, mimeType, file, nil)
+}
+
+// Upload with optional parameters. Files larger than MaxChunkSize are split
+// into chunks of whole MB by the filer. Version, Jwt, Verify and Compression
+// are ignored
+func (f *Filer) UploadWithOption(pathname string, mimeType string, file io.Reader, opt *UploadOption) error {
+	formData, contentType, err := makeFormData(filerPath(pathname), mimeType, "", file)
 	if err != nil {
 		return err
 	}
 
-	if !strings.HasPrefix(pathname, "/") {
-		pathname = "/" + pathname
+	header := make(http.Header)
+	query := opt.query()
+	if opt != nil {
+		opt.setPairs(header)
+		if opt.MaxChunkSize > 0 {
+			if query == "" {
+				query = "?"
+			} else {
+				query += "&"
+			}
+			query += "maxMB=" + strconv.FormatInt((opt.MaxChunkSize+1<<20-1)>>20, 10)
+		}
 	}
-
-	_, err = http.Post(f.Url+pathname, contentType, formData)
+	_, err = upload(f.pathUrl(pathname)+query, contentType, formData, header)
 	return err
 }
 
+// Does a file exist at pathname
+func (f *Filer) Exists(pathname string) (bool, error) {
+	resp, err := http.Head(f.pathUrl(pathname))
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return false, nil
+	case resp.StatusCode >= 200 && resp.StatusCode <= 299:
+		return true, nil
+	}
+	return false, errors.New(f.pathUrl(pathname) + ": " + resp.Status)
+}
+
+// Download file at pathname, the caller must close the returned body.
+// Files stored compressed are decompressed, see RegisterCompressor
+func (f *Filer) Get(pathname string) (body io.ReadCloser, info *FileInfo, err error) {
+	header := make(http.Header)
+	acceptEncoding(header)
+	resp, err := get(f.pathUrl(pathname), header)
+	if err != nil {
+		return
+	}
+	if err = decodeBody(resp); err != nil {
+		return
+	}
+	info = newFileInfo(resp)
+	if info.Name == "" {
+		info.Name = path.Base(filerPath(pathname))
+	}
+	return resp.Body, info, nil
+}
+
+// Information of file at pathname without downloading it
+func (f *Filer) Head(pathname string) (*FileInfo, error) {
+	resp, err := head(f.pathUrl(pathname), nil)
+	if err != nil {
+		return nil, err
+	}
+	info := newFileInfo(resp)
+	if info.Name == "" {
+		info.Name = path.Base(filerPath(pathname))
+	}
+	return info, nil
+}
+
 func (f *Filer) Delete(pathname string) error {
+	return del(f.pathUrl(pathname), nil)
+}
+
+// absolute path in filer
+func filerPath(pathname string) string {
 	if !strings.HasPrefix(pathname, "/") {
 		pathname = "/" + pathname
 	}
+	return pathname
+}
 
-	return del(f.Url + pathname)
+// url of pathname with each segment escaped
+func (f *Filer) pathUrl(pathname string) string {
+	segments := strings.Split(filerPath(pathname), "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return f.Url + strings.Join(segments, "/")
 }
@@ -0,0 +1,31 @@
+package cliutil
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestHumanBytes(t *testing.T) {
+	for n, want := range map[int64]string{0: "0 B", 1023: "1023 B", 1536: "1.5 KiB", 5 << 30: "5.0 GiB"} {
+		if got := HumanBytes(n); got != want {
+			t.Errorf("HumanBytes(%d) = %s, want %s", n, got, want)
+		}
+	}
+}
+
+func TestSHA256File(t *testing.T) {
+	file, err := ioutil.TempFile("", "cliutil")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	file.WriteString("hello")
+	file.Close()
+	if sum, err := SHA256File(file.Name()); err != nil || sum != "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824" {
+		t.Error(sum, err)
+	}
+	if _, err := SHA256File(file.Name() + ".missing"); err == nil {
+		t.Error("missing file should fail")
+	}
+}
@@ -0,0 +1,209 @@
+// Package fakeweed is an in-memory SeaweedFS master, volume server and filer
+// in one http server, for tests of the packages and commands on top of weedo.
+//
+// Fids are assigned on volume 3, whose ttl is 3d, and lookups of volume 9
+// fail. Filer paths are kept in Files without the leading slash.
+package fakeweed
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Default files listed in a page of a filer directory
+const pageLimit = 100
+
+// Fake server, fields must not be changed while requests are served
+type Server struct {
+	*httptest.Server
+	mu sync.Mutex
+	n  int // fids assigned
+
+	Files   map[string]string // fid or filer path -> content
+	Names   map[string]string // fid -> file name sent by Content-Disposition
+	Assigns []url.Values      // queries of /dir/assign
+	Uploads []*http.Request
+	Gets    int    // files downloaded
+	Deletes int    // files deleted
+	Pages   int    // pages of filer directories listed
+	Grown   string // query of the last /vol/grow
+
+	FailUpload string // uploads of the file name fail
+	FailDelete bool   // deletes fail
+
+	// volume id -> host:port of the volume server looked up, the server
+	// itself by default
+	Volumes map[string]string
+	// serve batch deletes of POST /delete, unsupported like old volume
+	// servers by default
+	BatchDelete bool
+	// "METHOD /path" -> Authorization header of the last request
+	Auths map[string]string
+}
+
+// Start a server with files, which may be nil
+func New(files map[string]string) *Server {
+	if files == nil {
+		files = map[string]string{}
+	}
+	s := &Server{Files: files, Names: map[string]string{}, Volumes: map[string]string{}, Auths: map[string]string{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// host:port of the server, as the url of the volume server
+func (s *Server) Host() string {
+	return strings.TrimPrefix(s.URL, "http://")
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	host := s.Host()
+	name := strings.TrimPrefix(r.URL.Path, "/")
+	s.Auths[r.Method+" "+r.URL.Path] = r.Header.Get("Authorization")
+	switch {
+	case r.URL.Path == "/dir/assign":
+		s.n++
+		s.Assigns = append(s.Assigns, r.URL.Query())
+		fmt.Fprintf(w, `{"fid":"3,%02x12345678","url":"%s","publicUrl":"%s"}`, s.n, host, host)
+	case r.URL.Path == "/dir/lookup":
+		if r.URL.Query().Get("volumeId") == "9" {
+			fmt.Fprint(w, `{"error":"volume id 9 not found"}`)
+			return
+		}
+		vhost := host
+		if h := s.Volumes[r.URL.Query().Get("volumeId")]; h != "" {
+			vhost = h
+		}
+		fmt.Fprintf(w, `{"locations":[{"url":"%s","publicUrl":"%s"},{"url":"b:8080","publicUrl":"b"}]}`, vhost, vhost)
+	case r.URL.Path == "/dir/status":
+		fmt.Fprintf(w, `{"Version":"0.77","Topology":{"Free":3,"Max":7,"DataCenters":[{"Id":"dc1","Free":3,"Max":7,
+			"Racks":[{"Id":"rack1","Free":3,"Max":7,"DataNodes":[{"Url":"%s","PublicUrl":"%s","Volumes":4,"Free":3,"Max":7}]}]}],
+			"layouts":[{"collection":"","replication":"000","ttl":"","writables":[3,4]}]}}`, host, host)
+	case r.URL.Path == "/status":
+		fmt.Fprint(w, `{"Version":"0.77","Volumes":[{"Id":3,"Size":2048,"RepType":"000","FileCount":2,"Ttl":{"Count":3,"Unit":3}}]}`)
+	case r.URL.Path == "/vol/grow":
+		s.Grown = r.URL.RawQuery
+		fmt.Fprint(w, `{"count":2}`)
+	case r.URL.Path == "/vol/vacuum":
+		fmt.Fprint(w, `{}`)
+	case r.URL.Path == "/delete":
+		if !s.BatchDelete {
+			http.NotFound(w, r)
+			return
+		}
+		s.batchDelete(w, r)
+	case r.Method == "POST":
+		s.upload(w, r, name)
+	case r.Method == "DELETE":
+		if s.FailDelete {
+			http.Error(w, "failed on purpose", http.StatusInternalServerError)
+			return
+		}
+		if _, ok := s.Files[name]; !ok {
+			http.NotFound(w, r)
+			return
+		}
+		delete(s.Files, name)
+		s.Deletes++
+		w.WriteHeader(http.StatusAccepted)
+	case strings.HasSuffix(r.URL.Path, "/"):
+		s.list(w, r)
+	default:
+		content, ok := s.Files[name]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if n := s.Names[name]; n != "" {
+			w.Header().Set("Content-Disposition", `inline; filename="`+n+`"`)
+		}
+		sum := md5.Sum([]byte(content))
+		w.Header().Set("Etag", `"`+hex.EncodeToString(sum[:])+`"`)
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		if r.Method == "HEAD" {
+			return
+		}
+		s.Gets++
+		fmt.Fprint(w, content)
+	}
+}
+
+func (s *Server) upload(w http.ResponseWriter, r *http.Request, name string) {
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if header.Filename == s.FailUpload {
+		fmt.Fprint(w, `{"error":"failed on purpose"}`)
+		return
+	}
+	data, _ := ioutil.ReadAll(file)
+	s.Uploads = append(s.Uploads, r)
+	s.Files[name] = string(data)
+	fmt.Fprintf(w, `{"size":%d}`, len(data))
+}
+
+// results of deleting fids of the form in order
+func (s *Server) batchDelete(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	results := []map[string]interface{}{}
+	for _, fid := range r.PostForm["fid"] {
+		content, ok := s.Files[fid]
+		switch {
+		case s.FailDelete:
+			results = append(results, map[string]interface{}{"fid": fid, "status": http.StatusInternalServerError, "error": "failed on purpose"})
+		case !ok:
+			results = append(results, map[string]interface{}{"fid": fid, "status": http.StatusNotFound, "error": "not found"})
+		default:
+			delete(s.Files, fid)
+			s.Deletes++
+			results = append(results, map[string]interface{}{"fid": fid, "status": http.StatusAccepted, "size": len(content)})
+		}
+	}
+	json.NewEncoder(w).Encode(results)
+}
+
+// filer directory, files in pages of limit after lastFileName
+func (s *Server) list(w http.ResponseWriter, r *http.Request) {
+	names, dirs := []string{}, map[string]bool{}
+	for k := range s.Files {
+		if rel := strings.TrimPrefix("/"+k, r.URL.Path); rel != "/"+k {
+			if i := strings.Index(rel, "/"); i > 0 {
+				dirs[rel[:i]] = true
+			} else if rel > r.FormValue("lastFileName") {
+				names = append(names, rel)
+			}
+		}
+	}
+	sort.Strings(names)
+	limit, err := strconv.Atoi(r.FormValue("limit"))
+	if err != nil {
+		limit = pageLimit
+	}
+	if len(names) > limit {
+		names = names[:limit]
+	}
+	files := []map[string]string{}
+	for _, name := range names {
+		files = append(files, map[string]string{"name": name})
+	}
+	subdirs := []map[string]string{}
+	for d := range dirs {
+		subdirs = append(subdirs, map[string]string{"name": d})
+	}
+	s.Pages++
+	json.NewEncoder(w).Encode(map[string]interface{}{"Directory": r.URL.Path, "Files": files, "Subdirectories": subdirs})
+}
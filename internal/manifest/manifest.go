@@ -0,0 +1,161 @@
+// Package manifest reads and writes the manifests of uploader, which are
+// read by downloader, weedo scrub and timekey indexes too.
+//
+// A manifest is JSON lines of Entry, or CSV with a header line of the
+// columns. Lines of "fid [text]" are read as well, for lists of fids.
+package manifest
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Line of manifest, a file uploaded or failed to
+type Entry struct {
+	Path   string    `json:"path"`
+	Fid    string    `json:"fid,omitempty"`
+	Size   int64     `json:"size"`
+	Mime   string    `json:"mime,omitempty"`
+	Sha256 string    `json:"sha256,omitempty"`
+	Time   time.Time `json:"timestamp"`
+	Error  string    `json:"error,omitempty"`
+	Mtime  time.Time `json:"mtime"`          // modification time of the local file
+	Dest   string    `json:"dest,omitempty"` // path in filer
+
+	// rest of a line of "fid [text]", a path or hash by the reader
+	Text string `json:"-"`
+}
+
+// Columns of CSV manifests, by the order of Entry.Record
+var CSVHeader = []string{"path", "fid", "size", "mime", "sha256", "timestamp", "error", "mtime", "dest"}
+
+// CSV record of e
+func (e *Entry) Record() []string {
+	return []string{e.Path, e.Fid, strconv.FormatInt(e.Size, 10), e.Mime, e.Sha256,
+		e.Time.Format(time.RFC3339Nano), e.Error, e.Mtime.Format(time.RFC3339Nano), e.Dest}
+}
+
+// fid or filer path the file is uploaded to
+func (e *Entry) Target() string {
+	if e.Dest != "" {
+		return e.Dest
+	}
+	return e.Fid
+}
+
+// entry of CSV record, columns are found by header
+func parseRecord(header, record []string) *Entry {
+	e := new(Entry)
+	for i, name := range header {
+		if i >= len(record) {
+			break
+		}
+		v := record[i]
+		switch name {
+		case "path":
+			e.Path = v
+		case "fid":
+			e.Fid = v
+		case "size":
+			e.Size, _ = strconv.ParseInt(v, 10, 64)
+		case "mime":
+			e.Mime = v
+		case "sha256":
+			e.Sha256 = v
+		case "timestamp":
+			e.Time, _ = time.Parse(time.RFC3339Nano, v)
+		case "error":
+			e.Error = v
+		case "mtime":
+			e.Mtime, _ = time.Parse(time.RFC3339Nano, v)
+		case "dest":
+			e.Dest = v
+		}
+	}
+	return e
+}
+
+// Read entries of the manifest at path, CSV if it ends with .csv
+func ReadFile(path string) ([]*Entry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	if strings.HasSuffix(strings.ToLower(path), ".csv") {
+		return readCSV(file)
+	}
+	return Read(file)
+}
+
+// Read entries of manifest from r, CSV if the first line is a header
+// starting with the path column, lines otherwise
+func Read(r io.Reader) ([]*Entry, error) {
+	br := bufio.NewReader(r)
+	if b, _ := br.Peek(len("path,")); string(b) == "path," {
+		return readCSV(br)
+	}
+	return readLines(br)
+}
+
+// Lines broken by crashes are skipped, as well as empty lines and lines
+// starting with #
+func readLines(r io.Reader) ([]*Entry, error) {
+	entries := []*Entry{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		e := new(Entry)
+		if line[0] == '{' {
+			if err := json.Unmarshal([]byte(line), e); err != nil {
+				log.Printf("Skipping manifest line %d: %s", n, err)
+				continue
+			}
+		} else {
+			e.Fid = line
+			if i := strings.IndexAny(line, " \t"); i > 0 {
+				e.Fid, e.Text = line[:i], strings.TrimSpace(line[i+1:])
+			}
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+// Columns may be added since the header was written, and records broken by
+// crashes are skipped
+func readCSV(r io.Reader) ([]*Entry, error) {
+	entries := []*Entry{}
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	var header []string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if _, ok := err.(*csv.ParseError); ok {
+			log.Println("Skipping manifest record:", err)
+			continue
+		}
+		if err != nil {
+			return entries, err
+		}
+		if header == nil {
+			header = record
+			continue
+		}
+		entries = append(entries, parseRecord(header, record))
+	}
+}
@@ -0,0 +1,53 @@
+package manifest
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRead(t *testing.T) {
+	e := &Entry{Path: "a.txt", Fid: "3,01", Size: 5, Sha256: "abc", Time: time.Unix(1, 0).UTC(), Dest: "/docs/a.txt"}
+	record := strings.Join(e.Record(), ",")
+	for name, content := range map[string]string{
+		"jsonl": "# comment\n\n" + `{"path":"a.txt","fid":"3,01","size":5,"sha256":"abc","dest":"/docs/a.txt"}` + "\n{broken\n",
+		"csv":   strings.Join(CSVHeader, ",") + "\n" + strings.Replace(record, "3,01", `"3,01"`, 1) + "\n",
+	} {
+		entries, err := Read(strings.NewReader(content))
+		if err != nil {
+			t.Fatal(name, err)
+		}
+		if len(entries) != 1 || entries[0].Fid != "3,01" || entries[0].Size != 5 || entries[0].Sha256 != "abc" || entries[0].Target() != "/docs/a.txt" {
+			t.Error(name, entries)
+		}
+	}
+
+	entries, err := Read(strings.NewReader("3,01\n3,02\tdocs/b.txt\n"))
+	if err != nil || len(entries) != 2 || entries[0].Fid != "3,01" || entries[1].Fid != "3,02" || entries[1].Text != "docs/b.txt" {
+		t.Error("fid lines:", entries, err)
+	}
+}
+
+func TestBrokenCSV(t *testing.T) {
+	dir, err := ioutil.TempDir("", "manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// a column added by a later version, and a record cut by a crash
+	path := filepath.Join(dir, "manifest.csv")
+	content := "path,fid,size,extra\na.txt,\"3,01\",5,x\nb.txt,\"3,02\"\nc.txt,\"3,03"
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Path != "a.txt" || entries[0].Size != 5 || entries[1].Fid != "3,02" {
+		t.Error("entries:", entries)
+	}
+}
@@ -0,0 +1,61 @@
+// json web token for volume servers
+package weedo
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Json Web Token sent to volume servers as "Authorization: Bearer <jwt>"
+type Jwt string
+
+type jwtClaims struct {
+	Fid string `json:"fid"`
+	Exp int64  `json:"exp,omitempty"`
+}
+
+var jwtHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// Generate a HS256 signed jwt for fid, the same as seaweedfs does with
+// jwt.signing.key (writes) or jwt.signing.read.key (reads) in security.toml.
+// The token never expires if expiresAfterSec <= 0
+func GenJwt(signingKey string, expiresAfterSec int, fid string) Jwt {
+	if signingKey == "" {
+		return ""
+	}
+	// versions of a fid share the same token
+	if i := strings.LastIndex(fid, "_"); i > 0 {
+		fid = fid[:i]
+	}
+	claims := jwtClaims{Fid: fid}
+	if expiresAfterSec > 0 {
+		claims.Exp = time.Now().Add(time.Duration(expiresAfterSec) * time.Second).Unix()
+	}
+	payload, _ := json.Marshal(claims)
+	unsigned := jwtHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	mac := hmac.New(sha256.New, []byte(signingKey))
+	mac.Write([]byte(unsigned))
+	return Jwt(unsigned + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)))
+}
+
+// set Authorization header of a request to volume server
+func (j Jwt) setHeader(h http.Header) {
+	if j != "" {
+		h.Set("Authorization", "Bearer "+string(j))
+	}
+}
+
+// get jwt from Authorization header returned by master
+func jwtFromHeader(h http.Header) Jwt {
+	bearer := h.Get("Authorization")
+	if len(bearer) > 7 && strings.ToUpper(bearer[:6]) == "BEARER" {
+		return Jwt(bearer[7:])
+	}
+	return ""
+}
@@ -35,39 +35,82 @@ type assignResp struct {
 	Url       string
 	PublicUrl string
 	Size      int64
+	Auth      Jwt
 	Error     string
 }
 
 // Assign multi file keys
 func (m *Master) AssignN(count int) (fid string, err error) {
-	if count <= 0 {
-		count = 1
+	return m.AssignWithOption(&AssignOption{Count: count})
+}
+
+// Optional parameters for assigning file keys
+type AssignOption struct {
+	Count       int
+	Collection  string
+	Replication string // e.g. "001"
+	DataCenter  string
+	Ttl         TTL // only volumes with the same ttl are assigned
+}
+
+// Assign file keys with optional parameters
+func (m *Master) AssignWithOption(opt *AssignOption) (fid string, err error) {
+	assign, err := m.assign(opt)
+	if err != nil {
+		return
+	}
+	fid = assign.Fid
+
+	return
+}
+
+func (m *Master) assign(opt *AssignOption) (*assignResp, error) {
+	if opt == nil {
+		opt = new(AssignOption)
+	}
+	v := url.Values{}
+	if opt.Count > 1 {
+		v.Set("count", strconv.Itoa(opt.Count))
+	}
+	if len(opt.Collection) > 0 {
+		v.Set("collection", opt.Collection)
+	}
+	if len(opt.Replication) > 0 {
+		v.Set("replication", opt.Replication)
+	}
+	if len(opt.DataCenter) > 0 {
+		v.Set("dataCenter", opt.DataCenter)
+	}
+	if !opt.Ttl.IsEmpty() {
+		v.Set("ttl", opt.Ttl.String())
 	}
 	url := m.Url + "/dir/assign"
-	if count > 1 {
-		url = url + "?count=" + strconv.Itoa(count)
+	if len(v) > 0 {
+		url = url + "?" + v.Encode()
 	}
 	resp, err := http.Get(url)
 	if err != nil {
-		return
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	assign := new(assignResp)
 	if err = decodeJson(resp.Body, assign); err != nil {
 		log.Println(err)
-		return
+		return nil, err
 	}
 
 	if assign.Error != "" {
 		err = errors.New(assign.Error)
 		log.Println(err)
-		return
+		return nil, err
+	}
+	// the jwt may be returned in header only
+	if assign.Auth == "" {
+		assign.Auth = jwtFromHeader(resp.Header)
 	}
 
-	fid = assign.Fid
-
-	return
+	return assign, nil
 }
 
 type lookupResp struct {
@@ -82,6 +125,18 @@ type location struct {
 
 // Lookup Volume
 func (m *Master) lookup(volumeId, collection string) (*Volume, error) {
+	vols, err := m.Lookup(volumeId, collection)
+	if err != nil {
+		return nil, err
+	}
+	return vols[0], nil
+}
+
+// Lookup all locations of volume, volumeId can be a fid as well
+func (m *Master) Lookup(volumeId, collection string) ([]*Volume, error) {
+	if i := strings.Index(volumeId, ","); i > 0 {
+		volumeId = volumeId[:i]
+	}
 	v := url.Values{}
 	v.Add("volumeId", volumeId)
 	if len(collection) > 0 {
@@ -102,19 +157,52 @@ func (m *Master) lookup(volumeId, collection string) (*Volume, error) {
 	if lookup.Error != "" {
 		return nil, errors.New(lookup.Error)
 	}
+	if len(lookup.Locations) == 0 {
+		return nil, errors.New("volume " + volumeId + " not found")
+	}
 
-	return NewVolume(lookup.Locations[0].Url, lookup.Locations[0].PublicUrl), nil
+	vols := make([]*Volume, len(lookup.Locations))
+	for i, l := range lookup.Locations {
+		vols[i] = NewVolume(l.Url, l.PublicUrl)
+	}
+	return vols, nil
+}
+
+// Get jwt of fid signed by master, which must be configured with
+// jwt.signing.key (write) or jwt.signing.read.key (read)
+func (m *Master) LookupJwt(fid string, read bool) (Jwt, error) {
+	v := url.Values{}
+	v.Add("fileId", fid)
+	if read {
+		v.Add("read", "yes")
+	}
+	resp, err := http.Get(m.Url + "/dir/lookup?" + v.Encode())
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	lookup := new(lookupResp)
+	if err = decodeJson(resp.Body, lookup); err != nil {
+		log.Println(err)
+		return "", err
+	}
+
+	if lookup.Error != "" {
+		return "", errors.New(lookup.Error)
+	}
+
+	return jwtFromHeader(resp.Header), nil
 }
 
 // Force Garbage Collection
 func (m *Master) GC(threshold float64) error {
-	resp, err := http.Get(m.Url + "/vol/vacuum?garbageThreshold=" +
-		strconv.FormatFloat(threshold, 'f', -1, 64))
+	resp, err := get(m.Url+"/vol/vacuum?garbageThreshold="+
+		strconv.FormatFloat(threshold, 'f', -1, 64), nil)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
-	// TODO: handle result
+	resp.Body.Close()
 	return nil
 }
 
@@ -132,17 +220,39 @@ func (m *Master) Grow(count int, collection, replica, dataCenter string) error {
 		v.Set("dataCenter", dataCenter)
 	}
 
-	_, err := http.Get(m.Url + "/vol/grow?" + v.Encode())
-	return err
+	resp, err := http.Get(m.Url + "/vol/grow?" + v.Encode())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	grow := struct{ Error string }{}
+	if err = decodeJson(resp.Body, &grow); err != nil {
+		return err
+	}
+	if grow.Error != "" {
+		return errors.New(grow.Error)
+	}
+	return nil
 }
 
 // Upload File Directly
 func (m *Master) Submit(filename, mimeType string, file io.Reader) (fid string, size int64, err error) {
-	data, contentType, err := makeFormData(filename, mimeType, file)
+	return m.SubmitWithOption(filename, mimeType, file, nil)
+}
+
+// Upload File Directly with optional parameters, Version, Jwt,
+// MaxChunkSize, Verify and Compression are ignored
+func (m *Master) SubmitWithOption(filename, mimeType string, file io.Reader, opt *UploadOption) (fid string, size int64, err error) {
+	data, contentType, err := makeFormData(filename, mimeType, "", file)
 	if err != nil {
 		return
 	}
-	resp, err := upload(m.Url+"/submit", contentType, data)
+	header := make(http.Header)
+	if opt != nil {
+		opt.setPairs(header)
+	}
+	resp, err := upload(m.Url+"/submit"+opt.query(), contentType, data, header)
 	if err == nil {
 		fid = resp.Fid
 		size = resp.Size
@@ -151,32 +261,35 @@ func (m *Master) Submit(filename, mimeType string, file io.Reader) (fid string,
 	return
 }
 
-type systemStatus struct {
-	Topology topology
+// Status of master with the topology of volume servers
+type SystemStatus struct {
+	Topology Topology
 	Version  string
 	Error    string
 }
 
-type topology struct {
-	DataCenters []dataCenter
+type Topology struct {
+	DataCenters []DataCenter
 	Free        int
 	Max         int
-	Layouts     []layout
+	Layouts     []Layout
 }
 
-type dataCenter struct {
+type DataCenter struct {
+	Id    string
 	Free  int
 	Max   int
-	Racks []rack
+	Racks []Rack
 }
 
-type rack struct {
-	DataNodes []dataNode
+type Rack struct {
+	Id        string
+	DataNodes []DataNode
 	Free      int
 	Max       int
 }
 
-type dataNode struct {
+type DataNode struct {
 	Free      int
 	Max       int
 	PublicUrl string
@@ -184,13 +297,22 @@ type dataNode struct {
 	Volumes   int
 }
 
-type layout struct {
+// Writable volumes of collection, replication and ttl
+type Layout struct {
+	Collection  string
 	Replication string
+	Ttl         TTL
 	Writables   []uint64
 }
 
 // Check System Status
 func (m *Master) Status() (err error) {
+	_, err = m.SystemStatus()
+	return
+}
+
+// Get System Status with the topology
+func (m *Master) SystemStatus() (status *SystemStatus, err error) {
 	resp, err := http.Get(m.Url + "/dir/status")
 	if err != nil {
 		return
@@ -198,16 +320,16 @@ func (m *Master) Status() (err error) {
 
 	defer resp.Body.Close()
 
-	status := new(systemStatus)
+	status = new(SystemStatus)
 	if err = decodeJson(resp.Body, status); err != nil {
 		log.Println(err)
-		return
+		return nil, err
 	}
 
 	if status.Error != "" {
 		err = errors.New(status.Error)
 		log.Println(err)
-		return
+		return nil, err
 	}
 	return
 }
@@ -0,0 +1,281 @@
+// range reads of stored files
+package weedo
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// bytes read ahead by small reads of RangeReader
+const readAhead = 64 << 10
+
+// File read by RangeReader changed since it was opened
+var ErrFileChanged = errors.New("file changed since opened")
+
+// GET n bytes of url from off, n < 0 to the end. The range is cut from the
+// whole content if the server doesn't support range requests, ContentLength
+// of the response is the size of the whole file then
+func getRange(url string, header http.Header, off, n int64) (*http.Response, error) {
+	if header == nil {
+		header = make(http.Header)
+	}
+	r := "bytes=" + strconv.FormatInt(off, 10) + "-"
+	if n >= 0 {
+		r += strconv.FormatInt(off+n-1, 10)
+	}
+	header.Set("Range", r)
+	resp, err := get(url, header)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+	if resp.ContentLength >= 0 && off >= resp.ContentLength {
+		err = io.EOF
+	} else {
+		_, err = io.CopyN(ioutil.Discard, resp.Body, off)
+	}
+	if err != nil {
+		resp.Body.Close()
+		if err == io.EOF {
+			code := http.StatusRequestedRangeNotSatisfiable
+			err = &HttpError{Url: url, StatusCode: code, Status: strconv.Itoa(code) + " " + http.StatusText(code)}
+		}
+		return nil, err
+	}
+	if n >= 0 {
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.LimitReader(resp.Body, n), resp.Body}
+	}
+	return resp, nil
+}
+
+// total size of the file of a range response, -1 if unknown
+func rangeSize(resp *http.Response) int64 {
+	if resp.StatusCode != http.StatusPartialContent {
+		return resp.ContentLength
+	}
+	cr := resp.Header.Get("Content-Range")
+	i := strings.LastIndex(cr, "/")
+	if i < 0 {
+		return -1
+	}
+	size, err := strconv.ParseInt(cr[i+1:], 10, 64)
+	if err != nil {
+		return -1
+	}
+	return size
+}
+
+// Read n bytes of file from off, n < 0 to the end. The caller must close
+// the returned body
+func (v *Volume) ReadRange(fid string, off, n int64, jwt ...Jwt) (io.ReadCloser, error) {
+	var j Jwt
+	if len(jwt) > 0 {
+		j = jwt[0]
+	}
+	resp, err := v.getRange(fid, j, nil, off, n)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (v *Volume) getRange(fid string, jwt Jwt, header http.Header, off, n int64) (*http.Response, error) {
+	if header == nil {
+		header = make(http.Header)
+	}
+	jwt.setHeader(header)
+	return getRange(v.Url+"/"+fid, header, off, n)
+}
+
+// Reader of a stored file by range requests, small reads are served from a
+// buffer read ahead. ReadAt is safe for concurrent use, Read and Seek are not.
+// Reads return ErrFileChanged if the file is replaced after it's opened
+type RangeReader struct {
+	read   func(header http.Header, off, n int64) (*http.Response, error)
+	info   *FileInfo
+	etag   string // of the first response, sent as If-Range
+	ranged bool   // ranges are supported by the server
+	off    int64  // of Read and Seek
+
+	mu     sync.Mutex
+	buf    []byte // read ahead from bufOff
+	bufOff int64
+}
+
+// Open file of fid for range reads, see RangeReader
+func (c *Client) Open(fid string) (*RangeReader, error) {
+	vol, err := c.Volume(fid, "")
+	if err != nil {
+		return nil, err
+	}
+	return openRange(func(header http.Header, off, n int64) (*http.Response, error) {
+		jwt, err := c.jwt(fid, false)
+		if err != nil {
+			return nil, err
+		}
+		return vol.getRange(fid, jwt, header, off, n)
+	}, func(resp *http.Response) *FileInfo {
+		return vol.fileInfo(fid, resp)
+	})
+}
+
+// Open file at pathname for range reads, see RangeReader
+func (f *Filer) Open(pathname string) (*RangeReader, error) {
+	pathname = filerPath(pathname)
+	return openRange(func(header http.Header, off, n int64) (*http.Response, error) {
+		return getRange(f.pathUrl(pathname), header, off, n)
+	}, func(resp *http.Response) *FileInfo {
+		info := newFileInfo(resp)
+		if info.Name == "" {
+			info.Name = path.Base(pathname)
+		}
+		return info
+	})
+}
+
+// read the first buffer to get the file info and size
+func openRange(read func(header http.Header, off, n int64) (*http.Response, error), fileInfo func(*http.Response) *FileInfo) (*RangeReader, error) {
+	r := &RangeReader{read: read}
+	resp, err := read(nil, 0, readAhead)
+	if e, ok := err.(*HttpError); ok && e.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		// no byte at 0, the file is empty
+		r.info = &FileInfo{}
+		return r, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	r.info = fileInfo(resp)
+	r.etag = resp.Header.Get("Etag")
+	r.ranged = resp.StatusCode == http.StatusPartialContent
+	if r.buf, err = ioutil.ReadAll(resp.Body); err != nil {
+		return nil, err
+	}
+	r.info.Size = rangeSize(resp)
+	if r.info.Size < 0 {
+		if len(r.buf) == readAhead {
+			return nil, errors.New("size of file unknown")
+		}
+		r.info.Size = int64(len(r.buf))
+	}
+	return r, nil
+}
+
+// Information of the file, Size is the size of the whole file
+func (r *RangeReader) Info() *FileInfo {
+	return r.info
+}
+
+func (r *RangeReader) Size() int64 {
+	return r.info.Size
+}
+
+func (r *RangeReader) ReadAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, errors.New("weedo.RangeReader.ReadAt: negative offset")
+	}
+	if off >= r.info.Size {
+		return 0, io.EOF
+	}
+	want := len(p)
+	if rest := r.info.Size - off; int64(len(p)) > rest {
+		p = p[:rest]
+	}
+	if len(p) >= readAhead {
+		n, err = r.fetch(p, off)
+	} else {
+		n, err = r.buffered(p, off)
+	}
+	if err == nil && n < want {
+		err = io.EOF
+	}
+	return
+}
+
+// range request of the file opened, the whole file is sent by If-Range
+// if it changed since
+func (r *RangeReader) get(off, n int64) (*http.Response, error) {
+	header := make(http.Header)
+	if r.etag != "" {
+		header.Set("If-Range", r.etag)
+	}
+	resp, err := r.read(header, off, n)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Header.Get("Etag") != r.etag || r.ranged && resp.StatusCode != http.StatusPartialContent {
+		resp.Body.Close()
+		return nil, ErrFileChanged
+	}
+	return resp, nil
+}
+
+// read p from off by a range request
+func (r *RangeReader) fetch(p []byte, off int64) (int, error) {
+	resp, err := r.get(off, int64(len(p)))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	n, err := io.ReadFull(resp.Body, p)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// read p from off through the read ahead buffer
+func (r *RangeReader) buffered(p []byte, off int64) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	end := off + int64(len(p))
+	if off < r.bufOff || end > r.bufOff+int64(len(r.buf)) {
+		size := int64(readAhead)
+		if rest := r.info.Size - off; size > rest {
+			size = rest
+		}
+		buf := make([]byte, size)
+		n, err := r.fetch(buf, off)
+		if err != nil {
+			r.buf = nil
+			return copy(p, buf[:n]), err
+		}
+		r.buf, r.bufOff = buf, off
+	}
+	return copy(p, r.buf[off-r.bufOff:]), nil
+}
+
+func (r *RangeReader) Read(p []byte) (n int, err error) {
+	n, err = r.ReadAt(p, r.off)
+	r.off += int64(n)
+	if n > 0 && err == io.EOF {
+		err = nil
+	}
+	return
+}
+
+func (r *RangeReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.off
+	case io.SeekEnd:
+		offset += r.info.Size
+	default:
+		return 0, errors.New("weedo.RangeReader.Seek: invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("weedo.RangeReader.Seek: negative position")
+	}
+	r.off = offset
+	return offset, nil
+}
@@ -0,0 +1,86 @@
+// spool readers of unknown size
+package weedo
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// Max bytes of a reader spooled in memory before spilling to a temp file
+const DefaultSpoolMemory = 8 << 20
+
+// Content of a reader with known size, Close removes the temp file if any
+type spooled struct {
+	io.Reader
+	size int64
+	file *os.File
+}
+
+func (s *spooled) Close() error {
+	if s.file == nil {
+		return nil
+	}
+	s.file.Close()
+	return os.Remove(s.file.Name())
+}
+
+// Size of the remaining content of r if it can be known without reading,
+// that is regular files and in-memory readers like bytes.Reader
+func readerSize(r io.Reader) (int64, bool) {
+	switch v := r.(type) {
+	case *os.File:
+		info, err := v.Stat()
+		if err != nil || !info.Mode().IsRegular() {
+			return 0, false
+		}
+		offset, err := v.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return 0, false
+		}
+		return info.Size() - offset, true
+	case interface {
+		Len() int
+	}:
+		return int64(v.Len()), true
+	}
+	return 0, false
+}
+
+// Read r until EOF into memory up to maxMemory bytes, and into a temp
+// file in dir for the rest
+func spool(r io.Reader, maxMemory int64, dir string) (*spooled, error) {
+	if size, ok := readerSize(r); ok {
+		return &spooled{Reader: r, size: size}, nil
+	}
+	buf := new(bytes.Buffer)
+	n, err := io.CopyN(buf, r, maxMemory+1)
+	if err == io.EOF {
+		return &spooled{Reader: buf, size: n}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := ioutil.TempFile(dir, "weedo-spool-")
+	if err != nil {
+		return nil, err
+	}
+	s := &spooled{file: file}
+	if _, err = buf.WriteTo(file); err == nil {
+		_, err = io.Copy(file, r)
+	}
+	if err == nil {
+		s.size, err = file.Seek(0, io.SeekCurrent)
+	}
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+	s.Reader = file
+	return s, nil
+}
@@ -1,7 +1,9 @@
-// Package timekey implements a customized Fid to get/set time/mime/size info in fid for seaweedfs
+// Package timekey implements the Fid of seaweedfs, and customizes it
+// to get/set time/mime/size info in fid
 package timekey
 
 import (
+	"database/sql/driver"
 	"errors"
 	"fmt"
 	"mime"
@@ -19,10 +21,12 @@ const (
 // >>> "%x"%(500*365*24*60*60*1000) # 50 years in nano seconds
 // 'e574609f000' # 5.5bytes 44bits
 
+// File id of seaweedfs in the form of <volume id>,<key><cookie>[_<version>]
 type Fid struct {
-	Id     uint32 // volume id
-	Key    uint64 // file key for volume
-	Cookie uint32 // cookie
+	Id      uint32 // volume id
+	Key     uint64 // file key for volume
+	Cookie  uint32 // cookie
+	Version uint32 // the _N suffix, 0 for none
 }
 
 func NewFid(volId, fullPath string) (*Fid, error) {
@@ -42,42 +46,113 @@ func (f *Fid) VolumeID() string {
 	return strconv.Itoa(int(f.Id))
 }
 
-// Fid in string form
-func (f *Fid) String() string {
-	return fmt.Sprintf("%d,%x%08x", f.Id, f.Key, f.Cookie)
+// Fid in string form, leading zero bytes of key are trimmed as seaweedfs does
+func (f Fid) String() string {
+	key := fmt.Sprintf("%016x", f.Key)
+	for len(key) > 2 && key[:2] == "00" {
+		key = key[2:]
+	}
+	if f.Version > 0 {
+		return fmt.Sprintf("%d,%s%08x_%d", f.Id, key, f.Cookie, f.Version)
+	}
+	return fmt.Sprintf("%d,%s%08x", f.Id, key, f.Cookie)
+}
+
+// Is f the zero Fid, which is not a valid fid
+func (f Fid) IsZero() bool {
+	return f == Fid{}
 }
 
-// Set Fid.Key to current nano seconds since 1970's
-// which is monotonouse increased :)
-// Let's hope this key would not collides with each other
-func (f *Fid) InsertTimeKey() {
-	f.Key = uint64(time.Now().UnixNano())
+// Set Fid.Key to a time key of the generator, or of a default generator
+// with random node id if not specified. See Generator for the key layout
+func (f *Fid) InsertTimeKey(g ...*Generator) {
+	gen := defaultGenerator
+	if len(g) > 0 && g[0] != nil {
+		gen = g[0]
+	}
+	f.Key = gen.Next()
 }
 
+// Cookie layouts, told apart by the highest 2 bits which are never 11 in
+// the legacy layout as it has only builtin mime ids, less than 768.
+//
+// legacy: mime id(10) | size in KB(22), sizes wrap above 4 GB
+//
+// v1: 11 | variant(1)=0 | mime id(10) | size exponent(5) | size mantissa(14)
+// size is mantissa<<exponent bytes, exact below 16 KB, and up to 35 TB
+//
+// v1 signed: 11 | variant(1)=1 | mime id(10) | size class(5) | hmac(14)
+// see InsertSignedCookie
+const (
+	cookieV1Mark   = uint32(3) << 30
+	cookieSigned   = uint32(1) << 29
+	mimeShift      = 19
+	mimeMask       = 1<<10 - 1
+	expShift       = 14
+	expMask        = 1<<5 - 1
+	mantissaBits   = 14
+	mantissaMask   = 1<<mantissaBits - 1
+	legacyMimeMask = 0xffc00000
+	legacySizeMask = 0x003fffff
+)
+
+// Max size in bytes that can be stored in cookie
+const MaxCookieSize = int64(mantissaMask) << expMask
+
+// Cookie layout versions
+const (
+	CookieLegacy = iota
+	CookieV1
+	CookieV1Signed
+)
+
 // Set Fid.Cookie(32 bits) according to the file infomation
-// Fid.Cookie contains the mime type info and file size(in KB)
-// midx takes the left 10 bits   mask: 0xffc00000
-// size tekes the rigth 22 bits  mask: 0x003fffff
+// Fid.Cookie contains the mime type info and file size in v1 layout,
+// sizes above MaxCookieSize are saved as MaxCookieSize
 func (f *Fid) InsertCookie(dataSize int, mimeType ...string) {
-	// midx
+	// set cookie
+	f.Cookie = cookieV1Mark | mimeBits(mimeType) | encodeSize(int64(dataSize))
+	return
+}
+
+// mime id of the first mime type in cookie, octet-stream if not found
+func mimeBits(mimeType []string) uint32 {
 	mtype := defaultMimeType
 	if len(mimeType) > 0 {
 		mtype = mimeType[0]
 	}
-	idx, ok := mmap[mtype]
+	idx, ok := MimeId(mtype)
 	if !ok {
-		idx = mmap[defaultMimeType]
+		idx, _ = MimeId(defaultMimeType)
 	}
-	midx := uint32(idx) << 22
+	return uint32(idx) << mimeShift
+}
 
-	// size
-	size := uint32(dataSize / 1024) // count in KB
-	if size == 0 {
-		size = 1
+// size as exponent(5) | mantissa(14), rounded down
+func encodeSize(size int64) uint32 {
+	if size < 0 {
+		size = 0
 	}
-	// set cookie
-	f.Cookie = midx + size
-	return
+	if size > MaxCookieSize {
+		size = MaxCookieSize
+	}
+	exp := uint32(0)
+	for size > mantissaMask {
+		size >>= 1
+		exp++
+	}
+	return exp<<expShift | uint32(size)
+}
+
+// Layout version of cookie
+func (f *Fid) CookieVersion() int {
+	if f.Cookie&cookieV1Mark == cookieV1Mark {
+		if f.Cookie&cookieSigned != 0 {
+			return CookieV1Signed
+		}
+		return CookieV1
+	}
+	return CookieLegacy
 }
 
 func (f *Fid) InsertKeyAndCookie(fullPath string) error {
@@ -92,44 +167,140 @@ func (f *Fid) InsertKeyAndCookie(fullPath string) error {
 	return nil
 }
 
+// Time when the key is inserted, in milliseconds for keys of Generator
+// and nano seconds for keys of old versions
 func (f *Fid) Time() time.Time {
-	return time.Unix(0, int64(f.Key))
+	return keyTime(f.Key)
+}
+
+// Node id of the Generator the key is inserted by, -1 for keys of old
+// versions
+func (f *Fid) Node() int {
+	if f.Key&keyFlag == 0 {
+		return -1
+	}
+	return int(f.Key >> seqBits & MaxNode)
 }
 
 // mime type for this fid
 func (f *Fid) MimeType() string {
-	midx := f.Cookie & 0xffc00000
-	midx = midx >> 22
-	return mslice[midx]
+	midx := (f.Cookie & legacyMimeMask) >> 22
+	if f.CookieVersion() != CookieLegacy {
+		midx = f.Cookie >> mimeShift & mimeMask
+	}
+	return MimeById(int(midx))
 }
 
-// Size in KB
-func (f *Fid) Size() int {
-	return int(f.Cookie & 0x003fffff)
+// Size in bytes, the real size is in [size, size+precision).
+// Sizes of v1 cookies are exact(precision 1) below 16 KB.
+// Signed cookies are in powers of 2 from 1 KB, and all sizes of 1 TB and
+// above are reported as 1 TB.
+// Legacy cookies count in KB, at least 1 KB, and wrap above 4 GB
+func (f *Fid) Size() (size int64, precision int64) {
+	switch f.CookieVersion() {
+	case CookieLegacy:
+		return int64(f.Cookie&legacySizeMask) * 1024, 1024
+	case CookieV1Signed:
+		return decodeSizeClass(f.Cookie >> expShift & expMask)
+	}
+	exp := f.Cookie >> expShift & expMask
+	return int64(f.Cookie&mantissaMask) << exp, 1 << exp
 }
 
+// Parse fid strictly: volume id must be a non-zero uint32, key is 1 to 16 hex
+// digits followed by 8 hex digits of cookie, and an optional _N version
 func ParseFid(s string) (*Fid, error) {
 	fid := new(Fid)
+	invalid := func(reason string) error {
+		return errors.New("Fid format invalid: " + reason + ": " + strconv.Quote(s))
+	}
 	a := strings.Split(s, ",")
-	if len(a) != 2 || len(a[1]) <= 8 {
-		return nil, errors.New("Fid format invalid")
+	if len(a) != 2 {
+		return nil, invalid("no comma")
 	}
 	// id
 	id, err := strconv.ParseUint(a[0], 10, 32)
-	if err != nil {
-		return nil, err
+	if err != nil || id == 0 {
+		return nil, invalid("volume id")
 	}
 	fid.Id = uint32(id)
+	// version
+	kc := a[1]
+	if i := strings.IndexByte(kc, '_'); i >= 0 {
+		v, err := strconv.ParseUint(kc[i+1:], 10, 32)
+		if err != nil || v == 0 {
+			return nil, invalid("version")
+		}
+		fid.Version = uint32(v)
+		kc = kc[:i]
+	}
+	if len(kc) <= 8 || len(kc) > 24 || !isHex(kc) {
+		return nil, invalid("key and cookie")
+	}
 	// key
-	index := len(a[1]) - 8
-	if fid.Key, err = strconv.ParseUint(a[1][:index], 16, 64); err != nil {
-		return nil, err
+	index := len(kc) - 8
+	if fid.Key, err = strconv.ParseUint(kc[:index], 16, 64); err != nil {
+		return nil, invalid("key")
 	}
 	// cookie
-	if cookie, err := strconv.ParseUint(a[1][index:], 16, 32); err != nil {
-		return nil, err
-	} else {
-		fid.Cookie = uint32(cookie)
+	cookie, err := strconv.ParseUint(kc[index:], 16, 32)
+	if err != nil {
+		return nil, invalid("cookie")
 	}
+	fid.Cookie = uint32(cookie)
 	return fid, nil
 }
+
+func isHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+// encoding.TextMarshaler, json and xml use it too
+func (f Fid) MarshalText() ([]byte, error) {
+	if f.IsZero() {
+		return []byte{}, nil
+	}
+	return []byte(f.String()), nil
+}
+
+// encoding.TextUnmarshaler, empty text is the zero Fid
+func (f *Fid) UnmarshalText(b []byte) error {
+	if len(b) == 0 {
+		*f = Fid{}
+		return nil
+	}
+	fid, err := ParseFid(string(b))
+	if err != nil {
+		return err
+	}
+	*f = *fid
+	return nil
+}
+
+// sql.Scanner, NULL is the zero Fid
+func (f *Fid) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*f = Fid{}
+		return nil
+	case string:
+		return f.UnmarshalText([]byte(v))
+	case []byte:
+		return f.UnmarshalText(v)
+	}
+	return fmt.Errorf("Fid: cannot scan %T", src)
+}
+
+// driver.Valuer, the zero Fid is stored as NULL
+func (f Fid) Value() (driver.Value, error) {
+	if f.IsZero() {
+		return nil, nil
+	}
+	return f.String(), nil
+}
@@ -1,7 +1,12 @@
 package timekey
 
 import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
 	"os"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -29,10 +34,405 @@ func TestCookie(t *testing.T) {
 		t.Fatal(err)
 	}
 	t.Log("fid.MimeType():", fid.MimeType())
-	t.Log("fid.Size():", fid.Size(), "KB")
+	size, precision := fid.Size()
+	t.Log("fid.Size():", size, precision)
 	info, err := os.Stat(testFile[0])
 	if err != nil {
 		t.Fatal(err)
 	}
-	t.Log("real file size:", info.Size()/1024)
+	t.Log("real file size:", info.Size())
+	if info.Size() < size || info.Size() >= size+precision {
+		t.Error("size not match")
+	}
+}
+
+func TestCookieSize(t *testing.T) {
+	fid := Fid{Id: 1}
+	for _, n := range []int64{0, 1, 1000, 16383, 16384, 16385, 5 << 30, 3 << 40, MaxCookieSize} {
+		fid.InsertCookie(int(n), "image/png")
+		size, precision := fid.Size()
+		if n < size || n >= size+precision {
+			t.Errorf("size of %d: %d, %d", n, size, precision)
+		}
+		if n < 16384 && precision != 1 {
+			t.Errorf("size of %d should be exact", n)
+		}
+		if precision > 1 && float64(precision)/float64(n) > 1.0/8192 {
+			t.Errorf("size of %d is too imprecise: %d", n, precision)
+		}
+		if fid.MimeType() != "image/png" || fid.CookieVersion() != CookieV1 {
+			t.Error("mime type not match", fid.MimeType())
+		}
+	}
+	fid.InsertCookie(int(MaxCookieSize) * 2)
+	if size, _ := fid.Size(); size != MaxCookieSize {
+		t.Error("size should be capped", size)
+	}
+
+	// legacy cookie: image/png, 5 KB
+	pngId, _ := MimeId("image/png")
+	fid.Cookie = uint32(pngId)<<22 | 5
+	if fid.CookieVersion() != CookieLegacy || fid.MimeType() != "image/png" {
+		t.Error("legacy mime type not match", fid.MimeType())
+	}
+	if size, precision := fid.Size(); size != 5*1024 || precision != 1024 {
+		t.Error("legacy size not match", size, precision)
+	}
+	// legacy mime indexes never mark v1
+	if uint32(CustomMimeMin-1)<<22&cookieV1Mark == cookieV1Mark {
+		t.Error("legacy mime index overlaps v1 mark")
+	}
+}
+
+func TestParseFid(t *testing.T) {
+	for _, s := range []string{"3,01637037d6", "7,ffffffffffffffff12345678_2", "4294967295,0100000000"} {
+		fid, err := ParseFid(s)
+		if err != nil {
+			t.Fatal(s, err)
+		}
+		if fid.String() != s {
+			t.Errorf("ParseFid(%q).String() = %q", s, fid)
+		}
+	}
+	fid, _ := ParseFid("3,01637037d6_12")
+	if fid.Id != 3 || fid.Key != 1 || fid.Cookie != 0x637037d6 || fid.Version != 12 {
+		t.Error("fid not match", *fid)
+	}
+	for _, s := range []string{"", "3", "0,01637037d6", "-3,01637037d6", "4294967296,01637037d6",
+		"3,637037d6", "3,1ffffffffffffffff12345678", "3,0163g037d6", "3,01637037d6_",
+		"3,01637037d6_0", "3,01637037d6_x", "3,01637037d6,1", " 3,01637037d6"} {
+		if _, err := ParseFid(s); err == nil {
+			t.Errorf("ParseFid(%q) should fail", s)
+		}
+	}
+}
+
+func TestFidEncoding(t *testing.T) {
+	type row struct {
+		Fid   Fid
+		Empty Fid
+	}
+	fid, _ := ParseFid("3,01637037d6_1")
+	b, err := json.Marshal(row{Fid: *fid})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"Fid":"3,01637037d6_1","Empty":""}` {
+		t.Error("json not match", string(b))
+	}
+	r := row{}
+	if err := json.Unmarshal(b, &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.Fid != *fid || !r.Empty.IsZero() {
+		t.Error("json round trip not match", r)
+	}
+	if err := json.Unmarshal([]byte(`{"Fid":"3,xyz"}`), &r); err == nil {
+		t.Error("invalid fid should fail")
+	}
+
+	// sql
+	v, err := fid.Value()
+	if err != nil || v != "3,01637037d6_1" {
+		t.Error("Value not match", v, err)
+	}
+	if v, _ := (Fid{}).Value(); v != nil {
+		t.Error("zero Fid should be NULL", v)
+	}
+	var f Fid
+	for _, src := range []interface{}{"3,01637037d6_1", []byte("3,01637037d6_1")} {
+		if err := f.Scan(src); err != nil || f != *fid {
+			t.Error("Scan not match", src, f, err)
+		}
+	}
+	if err := f.Scan(nil); err != nil || !f.IsZero() {
+		t.Error("Scan NULL not match", f, err)
+	}
+	if err := f.Scan(3); err == nil {
+		t.Error("Scan int should fail")
+	}
+}
+
+func TestGenerator(t *testing.T) {
+	if _, err := NewGenerator(MaxNode + 1); err == nil {
+		t.Error("node id out of range should fail")
+	}
+	g, err := NewGenerator(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// unique under concurrency
+	var mu sync.Mutex
+	keys := map[uint64]bool{}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10000; j++ {
+				k := g.Next()
+				mu.Lock()
+				keys[k] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if len(keys) != 80000 {
+		t.Error("keys collide:", 80000-len(keys))
+	}
+
+	// monotonic when clock goes backwards
+	now := time.Now()
+	g.now = func() time.Time { return now }
+	last := g.Next()
+	for _, d := range []time.Duration{-time.Hour, 0, -time.Second, time.Millisecond} {
+		g.now = func() time.Time { return now.Add(d) }
+		k := g.Next()
+		if k <= last {
+			t.Fatal("key not monotonic at", d)
+		}
+		last = k
+	}
+
+	// different nodes never collide
+	g2, _ := NewGenerator(8)
+	g2.now = g.now
+	if g.Next()>>seqBits&MaxNode == g2.Next()>>seqBits&MaxNode {
+		t.Error("node id not encoded")
+	}
+}
+
+func TestKeyTime(t *testing.T) {
+	g, _ := NewGenerator(1)
+	now := time.Date(2026, 10, 19, 8, 30, 0, 123456789, time.UTC)
+	g.now = func() time.Time { return now }
+	fid := Fid{Id: 3}
+	fid.InsertTimeKey(g)
+	if !fid.Time().Equal(now.Truncate(time.Millisecond)) {
+		t.Error("time not match", fid.Time())
+	}
+	if fid.Node() != 1 {
+		t.Error("node not match", fid.Node())
+	}
+	// keys of plain nano seconds
+	fid.Key = uint64(now.UnixNano())
+	if !fid.Time().Equal(now) {
+		t.Error("nano seconds time not match", fid.Time())
+	}
+	if fid.Node() != -1 {
+		t.Error("node of plain key", fid.Node())
+	}
+}
+
+// Mime ids of registry version 1 are saved in fids, never change them.
+// If this test fails, restore the changed ids instead of updating the hash
+func TestMimeRegistryFrozen(t *testing.T) {
+	const (
+		v1Count = 697
+		v1Hash  = "0581ca143e76a860e45d2ad3277c7a470fc3aa450517754ee23812ca51e372b6"
+	)
+	if len(builtinMimes) < v1Count || len(builtinMimes) > CustomMimeMin {
+		t.Fatal("builtin mime ids out of range:", len(builtinMimes))
+	}
+	h := sha256.New()
+	for id := 0; id < v1Count; id++ {
+		fmt.Fprintf(h, "%d\t%s\n", id, builtinMimes[id])
+	}
+	if fmt.Sprintf("%x", h.Sum(nil)) != v1Hash {
+		t.Fatal("mime ids of registry version 1 changed")
+	}
+	for mtype, id := range map[string]int{
+		"application/andrew-inset": 0, "application/octet-stream": 22,
+		"image/png": 445, "text/plain": 551, "x-epoc/x-sisx-app": 695,
+	} {
+		if got, ok := MimeId(mtype); !ok || got != id {
+			t.Errorf("MimeId(%q) = %d, want %d", mtype, got, id)
+		}
+	}
+}
+
+// every builtin type is saved in and read back from cookies, including
+// types not in lower case
+func TestMimeRoundTrip(t *testing.T) {
+	for id, mtype := range builtinMimes {
+		if mtype == "" {
+			continue
+		}
+		if got, ok := MimeId(mtype); !ok || got != id {
+			t.Errorf("MimeId(%q) = %d, want %d", mtype, got, id)
+		}
+		fid := Fid{Id: 1}
+		fid.InsertCookie(10, mtype)
+		if fid.MimeType() != mtype {
+			t.Errorf("mime type of cookie %q, want %q", fid.MimeType(), mtype)
+		}
+	}
+}
+
+func TestRegisterMime(t *testing.T) {
+	if id, ok := MimeId("Text/Plain; charset=utf-8"); !ok || MimeById(id) != "text/plain" {
+		t.Error("mime parameters should be ignored")
+	}
+	if MimeById(696) != defaultMimeType || MimeById(CustomMimeMax) != defaultMimeType {
+		t.Error("unknown ids should be octet-stream")
+	}
+	if err := RegisterMime(CustomMimeMin, "application/x-weedo-test"); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterMime(CustomMimeMin, "application/x-weedo-test"); err != nil {
+		t.Error("registering again should be a no-op:", err)
+	}
+	for id, mtype := range map[int]string{
+		CustomMimeMin:     "application/x-weedo-other", // id taken
+		CustomMimeMin + 1: "application/x-weedo-test",  // type taken
+		CustomMimeMin + 2: "text/plain",                // builtin type
+		CustomMimeMin - 1: "application/x-weedo-low",   // out of range
+		CustomMimeMax + 1: "application/x-weedo-high",  // out of range
+		CustomMimeMin + 3: "not a mime",
+	} {
+		if err := RegisterMime(id, mtype); err == nil {
+			t.Errorf("RegisterMime(%d, %q) should fail", id, mtype)
+		}
+	}
+	fid := Fid{Id: 1}
+	fid.InsertCookie(10, "application/x-weedo-test")
+	if fid.MimeType() != "application/x-weedo-test" {
+		t.Error("custom mime type not match", fid.MimeType())
+	}
+}
+
+func TestIndex(t *testing.T) {
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	x := NewIndex()
+	// one fid per hour, alternating png of i KB and pdf of i MB
+	for i := 9; i >= 0; i-- {
+		// generators are monotonic, use new ones to go back in time
+		g, _ := NewGenerator(1)
+		g.now = func() time.Time { return base.Add(time.Duration(i) * time.Hour) }
+		fid := Fid{Id: 1}
+		fid.InsertTimeKey(g)
+		if i%2 == 0 {
+			fid.InsertCookie(i<<10, "image/png")
+		} else {
+			fid.InsertCookie(i<<20, "application/pdf")
+		}
+		x.Add(fid)
+	}
+	if x.Len() != 10 {
+		t.Fatal("index length not match", x.Len())
+	}
+
+	hours := func(fids []Fid) (h []int) {
+		for _, f := range fids {
+			h = append(h, int(f.Time().Sub(base)/time.Hour))
+		}
+		return
+	}
+	for _, c := range []struct {
+		q    Query
+		want string
+	}{
+		{Query{}, "[0 1 2 3 4 5 6 7 8 9]"},
+		{Query{From: base.Add(2 * time.Hour), To: base.Add(5 * time.Hour)}, "[2 3 4]"},
+		{Query{To: base.Add(3 * time.Hour), MimePrefix: "image/"}, "[0 2]"},
+		{Query{MimePrefix: "application/pdf", MinSize: 5 << 20}, "[5 7 9]"},
+		{Query{From: base.Add(time.Hour), MaxSize: 8 << 10}, "[2 4 6 8]"},
+		{Query{From: base.Add(time.Hour), To: base.Add(time.Hour)}, "[]"},
+	} {
+		fids := x.Find(c.q)
+		if got := fmt.Sprint(hours(fids)); got != c.want {
+			t.Errorf("Find(%+v) = %s, want %s", c.q, got, c.want)
+		}
+		for _, f := range fids {
+			if !c.q.Match(&f) {
+				t.Error("Match not consistent with Find", f)
+			}
+		}
+	}
+
+	// stream
+	in := make(chan Fid)
+	go func() {
+		for _, f := range x.Find(Query{}) {
+			in <- f
+		}
+		close(in)
+	}()
+	n := 0
+	for range Filter(in, Query{MimePrefix: "image/"}) {
+		n++
+	}
+	if n != 5 {
+		t.Error("Filter count not match", n)
+	}
+
+	// load manifest
+	x = NewIndex()
+	if err := x.Load(strings.NewReader("# fids\n3,01637037d6\ta.txt\n\n4,02637037d6 b.txt\n")); err != nil {
+		t.Fatal(err)
+	}
+	if x.Len() != 2 {
+		t.Error("loaded length not match", x.Len())
+	}
+	if err := x.Load(strings.NewReader("3,xyz\n")); err == nil {
+		t.Error("invalid fid should fail")
+	}
+	// manifests of uploader
+	x = NewIndex()
+	jsonl := `{"path":"a.txt","fid":"3,01637037d6","size":5}` + "\n" + `{"path":"b.txt","error":"failed"}` + "\n" +
+		`{"path":"c.txt","dest":"/docs/c.txt","size":5}` + "\n"
+	csv := "path,fid,size,mime,sha256,timestamp,error\na.txt,\"3,01637037d6\",5,,,,\nb.txt,\"4,02637037d6\",5,,,,\n"
+	for _, m := range []string{jsonl, csv} {
+		if err := x.Load(strings.NewReader(m)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if x.Len() != 3 {
+		t.Error("fids of manifests:", x.Len())
+	}
+}
+
+func TestSignedCookie(t *testing.T) {
+	secret := []byte("secret")
+	// fixed key to keep the test deterministic, 14 bits macs may collide
+	fid := Fid{Id: 3, Key: 0x8000123456789abc}
+	for _, n := range []int64{0, 1023, 1024, 5000, 3 << 30, 1 << 40} {
+		fid.InsertSignedCookie(secret, int(n), "image/png")
+		if fid.CookieVersion() != CookieV1Signed || fid.MimeType() != "image/png" {
+			t.Fatal("signed cookie not match", fid.CookieVersion(), fid.MimeType())
+		}
+		size, precision := fid.Size()
+		if n < size || n >= size+precision {
+			t.Errorf("size of %d: %d, %d", n, size, precision)
+		}
+		if !fid.Verify(secret) {
+			t.Error("signed cookie should pass")
+		}
+		if fid.Verify([]byte("other")) {
+			t.Error("cookie of other secret should fail")
+		}
+	}
+	if size, _ := fid.Size(); size != 1<<40 {
+		t.Error("huge size should be 1 TB", size)
+	}
+
+	// any change of volume id, key, mime or size fails
+	fid.InsertSignedCookie(secret, 5000, "image/png")
+	for _, f := range []Fid{
+		{Id: 4, Key: fid.Key, Cookie: fid.Cookie},
+		{Id: 3, Key: fid.Key + 1, Cookie: fid.Cookie},
+		{Id: 3, Key: fid.Key, Cookie: fid.Cookie ^ 1<<mimeShift},
+		{Id: 3, Key: fid.Key, Cookie: fid.Cookie ^ 1<<expShift},
+		{Id: 3, Key: fid.Key, Cookie: fid.Cookie ^ 1},
+	} {
+		if f.Verify(secret) {
+			t.Error("tampered fid passes", f)
+		}
+	}
+
+	fid.InsertCookie(5000, "image/png")
+	if fid.Verify(secret) {
+		t.Error("unsigned cookie should fail")
+	}
 }
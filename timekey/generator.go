@@ -0,0 +1,85 @@
+package timekey
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Key layout of Generator, the highest bit tells it from keys of plain
+// nano seconds, which stay below 1<<63 until year 2262. From high to low bits:
+// flag(1) | milliseconds since keyEpoch(41) | node(10) | sequence(12)
+const (
+	keyFlag     = uint64(1) << 63
+	nodeBits    = 10
+	seqBits     = 12
+	MaxNode     = 1<<nodeBits - 1
+	maxSequence = 1<<seqBits - 1
+	timeShift   = nodeBits + seqBits
+	timeMask    = 1<<41 - 1
+)
+
+// 2020-01-01 00:00:00 UTC in milliseconds, keys are good for 69 years since
+var keyEpoch = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC).UnixNano() / 1e6
+
+// Snowflake-style generator of collision-free time keys.
+// Keys are unique within a generator and across generators with different
+// node ids, and monotonic even if the clock goes backwards
+type Generator struct {
+	mu   sync.Mutex
+	node uint64
+	last int64 // milliseconds since keyEpoch of the last key
+	seq  uint64
+	now  func() time.Time
+}
+
+// Create a generator with node id in [0, MaxNode], each process generating
+// keys for the same volumes should have its own node id
+func NewGenerator(node int) (*Generator, error) {
+	if node < 0 || node > MaxNode {
+		return nil, errors.New("node id out of range")
+	}
+	return &Generator{node: uint64(node), now: time.Now}, nil
+}
+
+// generator used by Fid.InsertTimeKey, with a random node id, which
+// collides with one of another process in 1024
+var defaultGenerator *Generator
+
+func init() {
+	b := make([]byte, 2)
+	rand.Read(b)
+	defaultGenerator, _ = NewGenerator(int(binary.BigEndian.Uint16(b) & MaxNode))
+}
+
+// Next key, it's greater than all keys generated before by g
+func (g *Generator) Next() uint64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ms := g.now().UnixNano()/1e6 - keyEpoch
+	if ms > g.last {
+		g.last = ms
+		g.seq = 0
+	} else {
+		// same millisecond or clock skew, keep counting on the last time
+		g.seq++
+		if g.seq > maxSequence {
+			// borrow the next millisecond
+			g.last++
+			g.seq = 0
+		}
+	}
+	return keyFlag | (uint64(g.last)&timeMask)<<timeShift | g.node<<seqBits | g.seq
+}
+
+// time encoded in key, keys not generated by Generator are nano seconds
+func keyTime(key uint64) time.Time {
+	if key&keyFlag == 0 {
+		return time.Unix(0, int64(key))
+	}
+	ms := int64(key>>timeShift&timeMask) + keyEpoch
+	return time.Unix(0, ms*1e6)
+}
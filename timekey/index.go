@@ -0,0 +1,140 @@
+package timekey
+
+import (
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Archs/weedo/internal/manifest"
+)
+
+// Conditions of fids to find, zero values are not checked
+type Query struct {
+	From, To   time.Time // uploaded in [From, To)
+	MimePrefix string    // e.g. "image/" or "application/pdf"
+	// size reported by Fid.Size in [MinSize, MaxSize]
+	MinSize, MaxSize int64
+}
+
+// Does f match q, the time is checked by Fid.Time
+func (q *Query) Match(f *Fid) bool {
+	t := f.Time()
+	if !q.From.IsZero() && t.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && !t.Before(q.To) {
+		return false
+	}
+	return q.matchInfo(f)
+}
+
+// match conditions other than time
+func (q *Query) matchInfo(f *Fid) bool {
+	if q.MimePrefix != "" && !strings.HasPrefix(f.MimeType(), q.MimePrefix) {
+		return false
+	}
+	if q.MinSize > 0 || q.MaxSize > 0 {
+		size, _ := f.Size()
+		if size < q.MinSize || q.MaxSize > 0 && size > q.MaxSize {
+			return false
+		}
+	}
+	return true
+}
+
+type indexEntry struct {
+	t   int64 // unix nano of Fid.Time
+	fid Fid
+}
+
+// In-memory index of timekey fids sorted by time, queries never touch
+// seaweedfs as time, mime type and size are all in the fids.
+// It's not safe for concurrent use
+type Index struct {
+	entries []indexEntry
+	sorted  bool
+}
+
+func NewIndex() *Index {
+	return &Index{sorted: true}
+}
+
+// Add fids to index
+func (x *Index) Add(fids ...Fid) {
+	for _, f := range fids {
+		x.entries = append(x.entries, indexEntry{f.Time().UnixNano(), f})
+	}
+	x.sorted = len(x.entries) <= 1
+}
+
+// Load fids of a manifest from r: JSON lines or CSV written by uploader, or
+// lines of "<fid> [path]". Empty lines, lines starting with # and entries
+// failed or uploaded into the filer are skipped, and invalid fids are errors
+func (x *Index) Load(r io.Reader) error {
+	entries, err := manifest.Read(r)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.Fid == "" || e.Error != "" {
+			continue
+		}
+		fid, err := ParseFid(e.Fid)
+		if err != nil {
+			return err
+		}
+		x.Add(*fid)
+	}
+	return nil
+}
+
+// Number of fids in index
+func (x *Index) Len() int {
+	return len(x.entries)
+}
+
+func (x *Index) sort() {
+	if x.sorted {
+		return
+	}
+	sort.SliceStable(x.entries, func(i, j int) bool {
+		return x.entries[i].t < x.entries[j].t
+	})
+	x.sorted = true
+}
+
+// Fids matching q, sorted by time
+func (x *Index) Find(q Query) []Fid {
+	x.sort()
+	lo, hi := 0, len(x.entries)
+	if !q.From.IsZero() {
+		from := q.From.UnixNano()
+		lo = sort.Search(hi, func(i int) bool { return x.entries[i].t >= from })
+	}
+	if !q.To.IsZero() {
+		to := q.To.UnixNano()
+		hi = sort.Search(hi, func(i int) bool { return x.entries[i].t >= to })
+	}
+	fids := []Fid{}
+	for i := lo; i < hi; i++ {
+		if q.matchInfo(&x.entries[i].fid) {
+			fids = append(fids, x.entries[i].fid)
+		}
+	}
+	return fids
+}
+
+// Filter a stream of fids by q, the returned channel is closed after fids
+func Filter(fids <-chan Fid, q Query) <-chan Fid {
+	out := make(chan Fid)
+	go func() {
+		defer close(out)
+		for f := range fids {
+			if q.Match(&f) {
+				out <- f
+			}
+		}
+	}()
+	return out
+}
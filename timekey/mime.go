@@ -0,0 +1,98 @@
+package timekey
+
+import (
+	"errors"
+	"mime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Version of the builtin mime types, ids of a version never change
+const MimeRegistryVersion = 1
+
+// Ids of mime types saved in cookies, in 10 bits:
+// [0, CustomMimeMin) are builtin, and [CustomMimeMin, CustomMimeMax]
+// are reserved for types registered by applications
+const (
+	CustomMimeMin = 768
+	CustomMimeMax = 1023
+)
+
+type mimeRegistry struct {
+	mu    sync.RWMutex
+	ids   map[string]int
+	types map[int]string
+}
+
+var mimes = newMimeRegistry()
+
+func newMimeRegistry() *mimeRegistry {
+	r := &mimeRegistry{
+		ids:   make(map[string]int),
+		types: make(map[int]string),
+	}
+	for id, mtype := range builtinMimes {
+		r.types[id] = mtype
+		if mtype != "" {
+			// looked up by MimeId in lower case, e.g. audio/AMR
+			r.ids[normalizeMime(mtype)] = id
+		}
+	}
+	return r
+}
+
+// Register a custom mime type with an id in [CustomMimeMin, CustomMimeMax].
+// The same id must be registered by all applications reading the fids,
+// registering the same type with the same id again is a no-op
+func RegisterMime(id int, mimeType string) error {
+	mtype := normalizeMime(mimeType)
+	if mtype == "" {
+		return errors.New("mime type invalid: " + strconv.Quote(mimeType))
+	}
+	if id < CustomMimeMin || id > CustomMimeMax {
+		return errors.New("custom mime id out of range: " + strconv.Itoa(id))
+	}
+
+	mimes.mu.Lock()
+	defer mimes.mu.Unlock()
+	if old, ok := mimes.types[id]; ok {
+		if old == mtype {
+			return nil
+		}
+		return errors.New("mime id " + strconv.Itoa(id) + " is taken by " + old)
+	}
+	if old, ok := mimes.ids[mtype]; ok {
+		return errors.New(mtype + " is registered with id " + strconv.Itoa(old))
+	}
+	mimes.ids[mtype] = id
+	mimes.types[id] = mtype
+	return nil
+}
+
+// Id of mime type, parameters such as charset are ignored
+func MimeId(mimeType string) (id int, ok bool) {
+	mimes.mu.RLock()
+	defer mimes.mu.RUnlock()
+	id, ok = mimes.ids[normalizeMime(mimeType)]
+	return
+}
+
+// Mime type of id, unknown ids and the legacy empty type are octet-stream
+func MimeById(id int) string {
+	mimes.mu.RLock()
+	defer mimes.mu.RUnlock()
+	if mtype := mimes.types[id]; mtype != "" {
+		return mtype
+	}
+	return defaultMimeType
+}
+
+// lower case media type without parameters, empty if invalid
+func normalizeMime(mimeType string) string {
+	mtype, _, err := mime.ParseMediaType(mimeType)
+	if err != nil || !strings.Contains(mtype, "/") {
+		return ""
+	}
+	return mtype
+}
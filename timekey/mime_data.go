@@ -1,718 +1,705 @@
 package timekey
 
-import (
-	"strings"
-)
-
-var (
-	mmap   = map[string]int{}
-	mslice = []string{}
-)
-
-func init() {
-	ss := strings.Split(mimeData, "\n")
-	for idx, mtype := range ss {
-		mslice = append(mslice, mtype)
-		mmap[mtype] = idx
-	}
+// Builtin mime types of registry version 1, keyed by their ids.
+// The ids are saved in cookies of fids, so they must never change:
+// new types may only take unused ids below CustomMimeMin.
+// Id 696 is the empty type of legacy cookies, decoded as octet-stream.
+var builtinMimes = []string{
+	0:   "application/andrew-inset",
+	1:   "application/annodex",
+	2:   "application/atom+xml",
+	3:   "application/dicom",
+	4:   "application/ecmascript",
+	5:   "application/epub+zip",
+	6:   "application/font-woff",
+	7:   "application/gml+xml",
+	8:   "application/gnunet-directory",
+	9:   "application/gzip",
+	10:  "application/illustrator",
+	11:  "application/javascript",
+	12:  "application/json",
+	13:  "application/mac-binhex40",
+	14:  "application/mathematica",
+	15:  "application/mathml+xml",
+	16:  "application/mbox",
+	17:  "application/metalink+xml",
+	18:  "application/metalink4+xml",
+	19:  "application/msword",
+	20:  "application/msword-template",
+	21:  "application/mxf",
+	22:  "application/octet-stream",
+	23:  "application/oda",
+	24:  "application/ogg",
+	25:  "application/oxps",
+	26:  "application/pdf",
+	27:  "application/pgp-encrypted",
+	28:  "application/pgp-keys",
+	29:  "application/pgp-signature",
+	30:  "application/pkcs10",
+	31:  "application/pkcs7-mime",
+	32:  "application/pkcs7-signature",
+	33:  "application/pkcs8",
+	34:  "application/pkix-cert",
+	35:  "application/pkix-crl",
+	36:  "application/pkix-pkipath",
+	37:  "application/postscript",
+	38:  "application/prs.plucker",
+	39:  "application/ram",
+	40:  "application/rdf+xml",
+	41:  "application/relax-ng-compact-syntax",
+	42:  "application/rss+xml",
+	43:  "application/rtf",
+	44:  "application/sdp",
+	45:  "application/sieve",
+	46:  "application/smil+xml",
+	47:  "application/sql",
+	48:  "application/vnd.adobe.flash.movie",
+	49:  "application/vnd.android.package-archive",
+	50:  "application/vnd.apple.mpegurl",
+	51:  "application/vnd.corel-draw",
+	52:  "application/vnd.debian.binary-package",
+	53:  "application/vnd.emusic-emusic_package",
+	54:  "application/vnd.google-earth.kml+xml",
+	55:  "application/vnd.google-earth.kmz",
+	56:  "application/vnd.hp-hpgl",
+	57:  "application/vnd.hp-pcl",
+	58:  "application/vnd.iccprofile",
+	59:  "application/vnd.lotus-1-2-3",
+	60:  "application/vnd.lotus-wordpro",
+	61:  "application/vnd.mozilla.xul+xml",
+	62:  "application/vnd.ms-access",
+	63:  "application/vnd.ms-asf",
+	64:  "application/vnd.ms-cab-compressed",
+	65:  "application/vnd.ms-excel",
+	66:  "application/vnd.ms-excel.addin.macroEnabled.12",
+	67:  "application/vnd.ms-excel.sheet.binary.macroEnabled.12",
+	68:  "application/vnd.ms-excel.sheet.macroEnabled.12",
+	69:  "application/vnd.ms-excel.template.macroEnabled.12",
+	70:  "application/vnd.ms-htmlhelp",
+	71:  "application/vnd.ms-powerpoint",
+	72:  "application/vnd.ms-powerpoint.addin.macroEnabled.12",
+	73:  "application/vnd.ms-powerpoint.presentation.macroEnabled.12",
+	74:  "application/vnd.ms-powerpoint.slide.macroEnabled.12",
+	75:  "application/vnd.ms-powerpoint.slideshow.macroEnabled.12",
+	76:  "application/vnd.ms-powerpoint.template.macroEnabled.12",
+	77:  "application/vnd.ms-publisher",
+	78:  "application/vnd.ms-tnef",
+	79:  "application/vnd.ms-word.document.macroEnabled.12",
+	80:  "application/vnd.ms-word.template.macroEnabled.12",
+	81:  "application/vnd.ms-works",
+	82:  "application/vnd.ms-wpl",
+	83:  "application/vnd.nintendo.snes.rom",
+	84:  "application/vnd.oasis.opendocument.chart",
+	85:  "application/vnd.oasis.opendocument.chart-template",
+	86:  "application/vnd.oasis.opendocument.database",
+	87:  "application/vnd.oasis.opendocument.formula",
+	88:  "application/vnd.oasis.opendocument.formula-template",
+	89:  "application/vnd.oasis.opendocument.graphics",
+	90:  "application/vnd.oasis.opendocument.graphics-flat-xml",
+	91:  "application/vnd.oasis.opendocument.graphics-template",
+	92:  "application/vnd.oasis.opendocument.image",
+	93:  "application/vnd.oasis.opendocument.presentation",
+	94:  "application/vnd.oasis.opendocument.presentation-flat-xml",
+	95:  "application/vnd.oasis.opendocument.presentation-template",
+	96:  "application/vnd.oasis.opendocument.spreadsheet",
+	97:  "application/vnd.oasis.opendocument.spreadsheet-flat-xml",
+	98:  "application/vnd.oasis.opendocument.spreadsheet-template",
+	99:  "application/vnd.oasis.opendocument.text",
+	100: "application/vnd.oasis.opendocument.text-flat-xml",
+	101: "application/vnd.oasis.opendocument.text-master",
+	102: "application/vnd.oasis.opendocument.text-template",
+	103: "application/vnd.oasis.opendocument.text-web",
+	104: "application/vnd.openofficeorg.extension",
+	105: "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	106: "application/vnd.openxmlformats-officedocument.presentationml.slide",
+	107: "application/vnd.openxmlformats-officedocument.presentationml.slideshow",
+	108: "application/vnd.openxmlformats-officedocument.presentationml.template",
+	109: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	110: "application/vnd.openxmlformats-officedocument.spreadsheetml.template",
+	111: "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	112: "application/vnd.openxmlformats-officedocument.wordprocessingml.template",
+	113: "application/vnd.palm",
+	114: "application/vnd.rn-realmedia",
+	115: "application/vnd.stardivision.calc",
+	116: "application/vnd.stardivision.chart",
+	117: "application/vnd.stardivision.draw",
+	118: "application/vnd.stardivision.impress",
+	119: "application/vnd.stardivision.mail",
+	120: "application/vnd.stardivision.math",
+	121: "application/vnd.stardivision.writer",
+	122: "application/vnd.sun.xml.calc",
+	123: "application/vnd.sun.xml.calc.template",
+	124: "application/vnd.sun.xml.draw",
+	125: "application/vnd.sun.xml.draw.template",
+	126: "application/vnd.sun.xml.impress",
+	127: "application/vnd.sun.xml.impress.template",
+	128: "application/vnd.sun.xml.math",
+	129: "application/vnd.sun.xml.writer",
+	130: "application/vnd.sun.xml.writer.global",
+	131: "application/vnd.sun.xml.writer.template",
+	132: "application/vnd.symbian.install",
+	133: "application/vnd.tcpdump.pcap",
+	134: "application/vnd.visio",
+	135: "application/vnd.wordperfect",
+	136: "application/winhlp",
+	137: "application/x-7z-compressed",
+	138: "application/x-abiword",
+	139: "application/x-ace",
+	140: "application/x-alz",
+	141: "application/x-amipro",
+	142: "application/x-aportisdoc",
+	143: "application/x-apple-diskimage",
+	144: "application/x-applix-spreadsheet",
+	145: "application/x-applix-word",
+	146: "application/x-arc",
+	147: "application/x-archive",
+	148: "application/x-arj",
+	149: "application/x-asp",
+	150: "application/x-awk",
+	151: "application/x-bcpio",
+	152: "application/x-bittorrent",
+	153: "application/x-blender",
+	154: "application/x-bzdvi",
+	155: "application/x-bzip",
+	156: "application/x-bzip-compressed-tar",
+	157: "application/x-bzpdf",
+	158: "application/x-bzpostscript",
+	159: "application/x-cb7",
+	160: "application/x-cbr",
+	161: "application/x-cbt",
+	162: "application/x-cbz",
+	163: "application/x-ccmx",
+	164: "application/x-cd-image",
+	165: "application/x-cdrdao-toc",
+	166: "application/x-chess-pgn",
+	167: "application/x-cisco-vpn-settings",
+	168: "application/x-class-file",
+	169: "application/x-compress",
+	170: "application/x-compressed-tar",
+	171: "application/x-core",
+	172: "application/x-cpio",
+	173: "application/x-cpio-compressed",
+	174: "application/x-csh",
+	175: "application/x-cue",
+	176: "application/x-dar",
+	177: "application/x-dbf",
+	178: "application/x-dc-rom",
+	179: "application/x-designer",
+	180: "application/x-desktop",
+	181: "application/x-dia-diagram",
+	182: "application/x-dia-shape",
+	183: "application/x-docbook+xml",
+	184: "application/x-dvi",
+	185: "application/x-e-theme",
+	186: "application/x-egon",
+	187: "application/x-executable",
+	188: "application/x-fictionbook+xml",
+	189: "application/x-fluid",
+	190: "application/x-font-afm",
+	191: "application/x-font-bdf",
+	192: "application/x-font-dos",
+	193: "application/x-font-framemaker",
+	194: "application/x-font-libgrx",
+	195: "application/x-font-linux-psf",
+	196: "application/x-font-otf",
+	197: "application/x-font-pcf",
+	198: "application/x-font-speedo",
+	199: "application/x-font-sunos-news",
+	200: "application/x-font-tex",
+	201: "application/x-font-tex-tfm",
+	202: "application/x-font-ttf",
+	203: "application/x-font-ttx",
+	204: "application/x-font-type1",
+	205: "application/x-font-vfont",
+	206: "application/x-frame",
+	207: "application/x-gameboy-rom",
+	208: "application/x-gamecube-rom",
+	209: "application/x-gba-rom",
+	210: "application/x-gdbm",
+	211: "application/x-gedcom",
+	212: "application/x-genesis-rom",
+	213: "application/x-gettext-translation",
+	214: "application/x-glade",
+	215: "application/x-gnucash",
+	216: "application/x-gnumeric",
+	217: "application/x-gnuplot",
+	218: "application/x-go-sgf",
+	219: "application/x-graphite",
+	220: "application/x-gtk-builder",
+	221: "application/x-gtktalog",
+	222: "application/x-gz-font-linux-psf",
+	223: "application/x-gzdvi",
+	224: "application/x-gzpdf",
+	225: "application/x-gzpostscript",
+	226: "application/x-hdf",
+	227: "application/x-hwp",
+	228: "application/x-hwt",
+	229: "application/x-ica",
+	230: "application/x-iff",
+	231: "application/x-ipod-firmware",
+	232: "application/x-it87",
+	233: "application/x-iwork-keynote-sffkey",
+	234: "application/x-java",
+	235: "application/x-java-archive",
+	236: "application/x-java-jce-keystore",
+	237: "application/x-java-jnlp-file",
+	238: "application/x-java-keystore",
+	239: "application/x-java-pack200",
+	240: "application/x-jbuilder-project",
+	241: "application/x-karbon",
+	242: "application/x-kchart",
+	243: "application/x-kexi-connectiondata",
+	244: "application/x-kexiproject-shortcut",
+	245: "application/x-kexiproject-sqlite2",
+	246: "application/x-kexiproject-sqlite3",
+	247: "application/x-kformula",
+	248: "application/x-killustrator",
+	249: "application/x-kivio",
+	250: "application/x-kontour",
+	251: "application/x-kpovmodeler",
+	252: "application/x-kpresenter",
+	253: "application/x-krita",
+	254: "application/x-kspread",
+	255: "application/x-kspread-crypt",
+	256: "application/x-ksysv-package",
+	257: "application/x-kugar",
+	258: "application/x-kword",
+	259: "application/x-kword-crypt",
+	260: "application/x-lha",
+	261: "application/x-lhz",
+	262: "application/x-lrzip",
+	263: "application/x-lrzip-compressed-tar",
+	264: "application/x-lyx",
+	265: "application/x-lz4",
+	266: "application/x-lzip",
+	267: "application/x-lzma",
+	268: "application/x-lzma-compressed-tar",
+	269: "application/x-lzop",
+	270: "application/x-m4",
+	271: "application/x-macbinary",
+	272: "application/x-magicpoint",
+	273: "application/x-markaby",
+	274: "application/x-matroska",
+	275: "application/x-mif",
+	276: "application/x-mimearchive",
+	277: "application/x-mobipocket-ebook",
+	278: "application/x-mozilla-bookmarks",
+	279: "application/x-ms-dos-executable",
+	280: "application/x-ms-wim",
+	281: "application/x-msi",
+	282: "application/x-mswinurl",
+	283: "application/x-mswrite",
+	284: "application/x-msx-rom",
+	285: "application/x-n64-rom",
+	286: "application/x-nautilus-link",
+	287: "application/x-navi-animation",
+	288: "application/x-nes-rom",
+	289: "application/x-netcdf",
+	290: "application/x-netshow-channel",
+	291: "application/x-nintendo-ds-rom",
+	292: "application/x-nzb",
+	293: "application/x-object",
+	294: "application/x-ole-storage",
+	295: "application/x-oleo",
+	296: "application/x-pagemaker",
+	297: "application/x-pak",
+	298: "application/x-par2",
+	299: "application/x-partial-download",
+	300: "application/x-pc-engine-rom",
+	301: "application/x-pef-executable",
+	302: "application/x-perl",
+	303: "application/x-php",
+	304: "application/x-pkcs12",
+	305: "application/x-pkcs7-certificates",
+	306: "application/x-planperfect",
+	307: "application/x-pocket-word",
+	308: "application/x-profile",
+	309: "application/x-pw",
+	310: "application/x-python-bytecode",
+	311: "application/x-qpress",
+	312: "application/x-qtiplot",
+	313: "application/x-quattropro",
+	314: "application/x-quicktime-media-link",
+	315: "application/x-qw",
+	316: "application/x-rar",
+	317: "application/x-raw-disk-image",
+	318: "application/x-raw-disk-image-xz-compressed",
+	319: "application/x-riff",
+	320: "application/x-rpm",
+	321: "application/x-ruby",
+	322: "application/x-sami",
+	323: "application/x-sc",
+	324: "application/x-shar",
+	325: "application/x-shared-library-la",
+	326: "application/x-sharedlib",
+	327: "application/x-shellscript",
+	328: "application/x-shorten",
+	329: "application/x-siag",
+	330: "application/x-slp",
+	331: "application/x-smaf",
+	332: "application/x-sms-rom",
+	333: "application/x-source-rpm",
+	334: "application/x-spss-por",
+	335: "application/x-spss-sav",
+	336: "application/x-sqlite2",
+	337: "application/x-sqlite3",
+	338: "application/x-stuffit",
+	339: "application/x-subrip",
+	340: "application/x-sv4cpio",
+	341: "application/x-sv4crc",
+	342: "application/x-t602",
+	343: "application/x-tar",
+	344: "application/x-tarz",
+	345: "application/x-tex-gf",
+	346: "application/x-tex-pk",
+	347: "application/x-tgif",
+	348: "application/x-theme",
+	349: "application/x-toutdoux",
+	350: "application/x-trash",
+	351: "application/x-trig",
+	352: "application/x-troff-man",
+	353: "application/x-troff-man-compressed",
+	354: "application/x-tzo",
+	355: "application/x-ufraw",
+	356: "application/x-ustar",
+	357: "application/x-wais-source",
+	358: "application/x-wii-rom",
+	359: "application/x-windows-themepack",
+	360: "application/x-wpg",
+	361: "application/x-wwf",
+	362: "application/x-x509-ca-cert",
+	363: "application/x-xbel",
+	364: "application/x-xliff",
+	365: "application/x-xpinstall",
+	366: "application/x-xz",
+	367: "application/x-xz-compressed-tar",
+	368: "application/x-xzpdf",
+	369: "application/x-yaml",
+	370: "application/x-zerosize",
+	371: "application/x-zip-compressed-fb2",
+	372: "application/x-zoo",
+	373: "application/xhtml+xml",
+	374: "application/xml",
+	375: "application/xml-dtd",
+	376: "application/xml-external-parsed-entity",
+	377: "application/xslt+xml",
+	378: "application/xspf+xml",
+	379: "application/zip",
+	380: "application/zlib",
+	381: "audio/AMR",
+	382: "audio/AMR-WB",
+	383: "audio/aac",
+	384: "audio/ac3",
+	385: "audio/annodex",
+	386: "audio/basic",
+	387: "audio/flac",
+	388: "audio/midi",
+	389: "audio/mp2",
+	390: "audio/mp4",
+	391: "audio/mpeg",
+	392: "audio/ogg",
+	393: "audio/prs.sid",
+	394: "audio/vnd.dts",
+	395: "audio/vnd.dts.hd",
+	396: "audio/vnd.rn-realaudio",
+	397: "audio/webm",
+	398: "audio/x-adpcm",
+	399: "audio/x-aifc",
+	400: "audio/x-aiff",
+	401: "audio/x-amzxml",
+	402: "audio/x-ape",
+	403: "audio/x-flac+ogg",
+	404: "audio/x-gsm",
+	405: "audio/x-iriver-pla",
+	406: "audio/x-it",
+	407: "audio/x-m4b",
+	408: "audio/x-matroska",
+	409: "audio/x-minipsf",
+	410: "audio/x-mo3",
+	411: "audio/x-mod",
+	412: "audio/x-mpegurl",
+	413: "audio/x-ms-asx",
+	414: "audio/x-ms-wma",
+	415: "audio/x-musepack",
+	416: "audio/x-opus+ogg",
+	417: "audio/x-psf",
+	418: "audio/x-psflib",
+	419: "audio/x-riff",
+	420: "audio/x-s3m",
+	421: "audio/x-scpls",
+	422: "audio/x-speex",
+	423: "audio/x-speex+ogg",
+	424: "audio/x-stm",
+	425: "audio/x-tta",
+	426: "audio/x-voc",
+	427: "audio/x-vorbis+ogg",
+	428: "audio/x-wav",
+	429: "audio/x-wavpack",
+	430: "audio/x-wavpack-correction",
+	431: "audio/x-xi",
+	432: "audio/x-xm",
+	433: "audio/x-xmf",
+	434: "image/bmp",
+	435: "image/cgm",
+	436: "image/dpx",
+	437: "image/fax-g3",
+	438: "image/fits",
+	439: "image/g3fax",
+	440: "image/gif",
+	441: "image/ief",
+	442: "image/jp2",
+	443: "image/jpeg",
+	444: "image/openraster",
+	445: "image/png",
+	446: "image/rle",
+	447: "image/svg+xml",
+	448: "image/svg+xml-compressed",
+	449: "image/tiff",
+	450: "image/vnd.adobe.photoshop",
+	451: "image/vnd.djvu",
+	452: "image/vnd.dwg",
+	453: "image/vnd.dxf",
+	454: "image/vnd.microsoft.icon",
+	455: "image/vnd.ms-modi",
+	456: "image/vnd.rn-realpix",
+	457: "image/vnd.wap.wbmp",
+	458: "image/webp",
+	459: "image/x-3ds",
+	460: "image/x-adobe-dng",
+	461: "image/x-applix-graphics",
+	462: "image/x-bzeps",
+	463: "image/x-canon-cr2",
+	464: "image/x-canon-crw",
+	465: "image/x-cmu-raster",
+	466: "image/x-compressed-xcf",
+	467: "image/x-dcraw",
+	468: "image/x-dds",
+	469: "image/x-dib",
+	470: "image/x-emf",
+	471: "image/x-eps",
+	472: "image/x-exr",
+	473: "image/x-fpx",
+	474: "image/x-fuji-raf",
+	475: "image/x-gzeps",
+	476: "image/x-icns",
+	477: "image/x-ilbm",
+	478: "image/x-jng",
+	479: "image/x-kodak-dcr",
+	480: "image/x-kodak-k25",
+	481: "image/x-kodak-kdc",
+	482: "image/x-lwo",
+	483: "image/x-lws",
+	484: "image/x-macpaint",
+	485: "image/x-minolta-mrw",
+	486: "image/x-msod",
+	487: "image/x-niff",
+	488: "image/x-nikon-nef",
+	489: "image/x-olympus-orf",
+	490: "image/x-panasonic-raw",
+	491: "image/x-panasonic-raw2",
+	492: "image/x-pcx",
+	493: "image/x-pentax-pef",
+	494: "image/x-photo-cd",
+	495: "image/x-pict",
+	496: "image/x-portable-anymap",
+	497: "image/x-portable-bitmap",
+	498: "image/x-portable-graymap",
+	499: "image/x-portable-pixmap",
+	500: "image/x-quicktime",
+	501: "image/x-rgb",
+	502: "image/x-sgi",
+	503: "image/x-sigma-x3f",
+	504: "image/x-skencil",
+	505: "image/x-sony-arw",
+	506: "image/x-sony-sr2",
+	507: "image/x-sony-srf",
+	508: "image/x-sun-raster",
+	509: "image/x-tga",
+	510: "image/x-tiff-multipage",
+	511: "image/x-win-bitmap",
+	512: "image/x-wmf",
+	513: "image/x-xbitmap",
+	514: "image/x-xcf",
+	515: "image/x-xcursor",
+	516: "image/x-xfig",
+	517: "image/x-xpixmap",
+	518: "image/x-xwindowdump",
+	519: "inode/blockdevice",
+	520: "inode/chardevice",
+	521: "inode/directory",
+	522: "inode/fifo",
+	523: "inode/mount-point",
+	524: "inode/socket",
+	525: "inode/symlink",
+	526: "message/delivery-status",
+	527: "message/disposition-notification",
+	528: "message/external-body",
+	529: "message/news",
+	530: "message/partial",
+	531: "message/rfc822",
+	532: "message/x-gnu-rmail",
+	533: "model/vrml",
+	534: "multipart/alternative",
+	535: "multipart/appledouble",
+	536: "multipart/digest",
+	537: "multipart/encrypted",
+	538: "multipart/mixed",
+	539: "multipart/related",
+	540: "multipart/report",
+	541: "multipart/signed",
+	542: "multipart/x-mixed-replace",
+	543: "text/cache-manifest",
+	544: "text/calendar",
+	545: "text/css",
+	546: "text/csv",
+	547: "text/enriched",
+	548: "text/html",
+	549: "text/htmlh",
+	550: "text/markdown",
+	551: "text/plain",
+	552: "text/rfc822-headers",
+	553: "text/richtext",
+	554: "text/sgml",
+	555: "text/spreadsheet",
+	556: "text/tab-separated-values",
+	557: "text/troff",
+	558: "text/vcard",
+	559: "text/vnd.graphviz",
+	560: "text/vnd.rn-realtext",
+	561: "text/vnd.sun.j2me.app-descriptor",
+	562: "text/vnd.trolltech.linguist",
+	563: "text/vnd.wap.wml",
+	564: "text/vnd.wap.wmlscript",
+	565: "text/vtt",
+	566: "text/x-adasrc",
+	567: "text/x-authors",
+	568: "text/x-bibtex",
+	569: "text/x-c++hdr",
+	570: "text/x-c++src",
+	571: "text/x-changelog",
+	572: "text/x-chdr",
+	573: "text/x-cmake",
+	574: "text/x-cobol",
+	575: "text/x-copying",
+	576: "text/x-credits",
+	577: "text/x-csharp",
+	578: "text/x-csrc",
+	579: "text/x-dcl",
+	580: "text/x-dsl",
+	581: "text/x-dsrc",
+	582: "text/x-eiffel",
+	583: "text/x-emacs-lisp",
+	584: "text/x-erlang",
+	585: "text/x-fortran",
+	586: "text/x-genie",
+	587: "text/x-gettext-translation",
+	588: "text/x-gettext-translation-template",
+	589: "text/x-go",
+	590: "text/x-google-video-pointer",
+	591: "text/x-haskell",
+	592: "text/x-iMelody",
+	593: "text/x-idl",
+	594: "text/x-install",
+	595: "text/x-iptables",
+	596: "text/x-java",
+	597: "text/x-ldif",
+	598: "text/x-lilypond",
+	599: "text/x-literate-haskell",
+	600: "text/x-log",
+	601: "text/x-lua",
+	602: "text/x-makefile",
+	603: "text/x-matlab",
+	604: "text/x-microdvd",
+	605: "text/x-moc",
+	606: "text/x-modelica",
+	607: "text/x-mof",
+	608: "text/x-mpsub",
+	609: "text/x-mrml",
+	610: "text/x-ms-regedit",
+	611: "text/x-mup",
+	612: "text/x-nfo",
+	613: "text/x-objcsrc",
+	614: "text/x-ocaml",
+	615: "text/x-ocl",
+	616: "text/x-ooc",
+	617: "text/x-opml+xml",
+	618: "text/x-pascal",
+	619: "text/x-patch",
+	620: "text/x-python",
+	621: "text/x-qml",
+	622: "text/x-readme",
+	623: "text/x-reject",
+	624: "text/x-rpm-spec",
+	625: "text/x-scala",
+	626: "text/x-scheme",
+	627: "text/x-scons",
+	628: "text/x-setext",
+	629: "text/x-ssa",
+	630: "text/x-subviewer",
+	631: "text/x-svhdr",
+	632: "text/x-svsrc",
+	633: "text/x-tcl",
+	634: "text/x-tex",
+	635: "text/x-texinfo",
+	636: "text/x-troff-me",
+	637: "text/x-troff-mm",
+	638: "text/x-troff-ms",
+	639: "text/x-txt2tags",
+	640: "text/x-uil",
+	641: "text/x-uri",
+	642: "text/x-uuencode",
+	643: "text/x-vala",
+	644: "text/x-verilog",
+	645: "text/x-vhdl",
+	646: "text/x-xmi",
+	647: "text/x-xslfo",
+	648: "text/xmcd",
+	649: "video/3gpp",
+	650: "video/3gpp2",
+	651: "video/annodex",
+	652: "video/dv",
+	653: "video/isivideo",
+	654: "video/mp2t",
+	655: "video/mp4",
+	656: "video/mpeg",
+	657: "video/ogg",
+	658: "video/quicktime",
+	659: "video/vnd.mpegurl",
+	660: "video/vnd.rn-realvideo",
+	661: "video/vnd.vivo",
+	662: "video/wavelet",
+	663: "video/webm",
+	664: "video/x-anim",
+	665: "video/x-flic",
+	666: "video/x-flv",
+	667: "video/x-javafx",
+	668: "video/x-matroska",
+	669: "video/x-matroska-3d",
+	670: "video/x-mng",
+	671: "video/x-ms-wmv",
+	672: "video/x-msvideo",
+	673: "video/x-nsv",
+	674: "video/x-ogm+ogg",
+	675: "video/x-sgi-movie",
+	676: "video/x-theora+ogg",
+	677: "x-content/audio-cdda",
+	678: "x-content/audio-dvd",
+	679: "x-content/audio-player",
+	680: "x-content/blank-bd",
+	681: "x-content/blank-cd",
+	682: "x-content/blank-dvd",
+	683: "x-content/blank-hddvd",
+	684: "x-content/ebook-reader",
+	685: "x-content/image-dcf",
+	686: "x-content/image-picturecd",
+	687: "x-content/software",
+	688: "x-content/unix-software",
+	689: "x-content/video-bluray",
+	690: "x-content/video-dvd",
+	691: "x-content/video-hddvd",
+	692: "x-content/video-svcd",
+	693: "x-content/video-vcd",
+	694: "x-content/win32-software",
+	695: "x-epoc/x-sisx-app",
+	696: "",
 }
-
-const (
-	mimeData = `application/andrew-inset
-application/annodex
-application/atom+xml
-application/dicom
-application/ecmascript
-application/epub+zip
-application/font-woff
-application/gml+xml
-application/gnunet-directory
-application/gzip
-application/illustrator
-application/javascript
-application/json
-application/mac-binhex40
-application/mathematica
-application/mathml+xml
-application/mbox
-application/metalink+xml
-application/metalink4+xml
-application/msword
-application/msword-template
-application/mxf
-application/octet-stream
-application/oda
-application/ogg
-application/oxps
-application/pdf
-application/pgp-encrypted
-application/pgp-keys
-application/pgp-signature
-application/pkcs10
-application/pkcs7-mime
-application/pkcs7-signature
-application/pkcs8
-application/pkix-cert
-application/pkix-crl
-application/pkix-pkipath
-application/postscript
-application/prs.plucker
-application/ram
-application/rdf+xml
-application/relax-ng-compact-syntax
-application/rss+xml
-application/rtf
-application/sdp
-application/sieve
-application/smil+xml
-application/sql
-application/vnd.adobe.flash.movie
-application/vnd.android.package-archive
-application/vnd.apple.mpegurl
-application/vnd.corel-draw
-application/vnd.debian.binary-package
-application/vnd.emusic-emusic_package
-application/vnd.google-earth.kml+xml
-application/vnd.google-earth.kmz
-application/vnd.hp-hpgl
-application/vnd.hp-pcl
-application/vnd.iccprofile
-application/vnd.lotus-1-2-3
-application/vnd.lotus-wordpro
-application/vnd.mozilla.xul+xml
-application/vnd.ms-access
-application/vnd.ms-asf
-application/vnd.ms-cab-compressed
-application/vnd.ms-excel
-application/vnd.ms-excel.addin.macroEnabled.12
-application/vnd.ms-excel.sheet.binary.macroEnabled.12
-application/vnd.ms-excel.sheet.macroEnabled.12
-application/vnd.ms-excel.template.macroEnabled.12
-application/vnd.ms-htmlhelp
-application/vnd.ms-powerpoint
-application/vnd.ms-powerpoint.addin.macroEnabled.12
-application/vnd.ms-powerpoint.presentation.macroEnabled.12
-application/vnd.ms-powerpoint.slide.macroEnabled.12
-application/vnd.ms-powerpoint.slideshow.macroEnabled.12
-application/vnd.ms-powerpoint.template.macroEnabled.12
-application/vnd.ms-publisher
-application/vnd.ms-tnef
-application/vnd.ms-word.document.macroEnabled.12
-application/vnd.ms-word.template.macroEnabled.12
-application/vnd.ms-works
-application/vnd.ms-wpl
-application/vnd.nintendo.snes.rom
-application/vnd.oasis.opendocument.chart
-application/vnd.oasis.opendocument.chart-template
-application/vnd.oasis.opendocument.database
-application/vnd.oasis.opendocument.formula
-application/vnd.oasis.opendocument.formula-template
-application/vnd.oasis.opendocument.graphics
-application/vnd.oasis.opendocument.graphics-flat-xml
-application/vnd.oasis.opendocument.graphics-template
-application/vnd.oasis.opendocument.image
-application/vnd.oasis.opendocument.presentation
-application/vnd.oasis.opendocument.presentation-flat-xml
-application/vnd.oasis.opendocument.presentation-template
-application/vnd.oasis.opendocument.spreadsheet
-application/vnd.oasis.opendocument.spreadsheet-flat-xml
-application/vnd.oasis.opendocument.spreadsheet-template
-application/vnd.oasis.opendocument.text
-application/vnd.oasis.opendocument.text-flat-xml
-application/vnd.oasis.opendocument.text-master
-application/vnd.oasis.opendocument.text-template
-application/vnd.oasis.opendocument.text-web
-application/vnd.openofficeorg.extension
-application/vnd.openxmlformats-officedocument.presentationml.presentation
-application/vnd.openxmlformats-officedocument.presentationml.slide
-application/vnd.openxmlformats-officedocument.presentationml.slideshow
-application/vnd.openxmlformats-officedocument.presentationml.template
-application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
-application/vnd.openxmlformats-officedocument.spreadsheetml.template
-application/vnd.openxmlformats-officedocument.wordprocessingml.document
-application/vnd.openxmlformats-officedocument.wordprocessingml.template
-application/vnd.palm
-application/vnd.rn-realmedia
-application/vnd.stardivision.calc
-application/vnd.stardivision.chart
-application/vnd.stardivision.draw
-application/vnd.stardivision.impress
-application/vnd.stardivision.mail
-application/vnd.stardivision.math
-application/vnd.stardivision.writer
-application/vnd.sun.xml.calc
-application/vnd.sun.xml.calc.template
-application/vnd.sun.xml.draw
-application/vnd.sun.xml.draw.template
-application/vnd.sun.xml.impress
-application/vnd.sun.xml.impress.template
-application/vnd.sun.xml.math
-application/vnd.sun.xml.writer
-application/vnd.sun.xml.writer.global
-application/vnd.sun.xml.writer.template
-application/vnd.symbian.install
-application/vnd.tcpdump.pcap
-application/vnd.visio
-application/vnd.wordperfect
-application/winhlp
-application/x-7z-compressed
-application/x-abiword
-application/x-ace
-application/x-alz
-application/x-amipro
-application/x-aportisdoc
-application/x-apple-diskimage
-application/x-applix-spreadsheet
-application/x-applix-word
-application/x-arc
-application/x-archive
-application/x-arj
-application/x-asp
-application/x-awk
-application/x-bcpio
-application/x-bittorrent
-application/x-blender
-application/x-bzdvi
-application/x-bzip
-application/x-bzip-compressed-tar
-application/x-bzpdf
-application/x-bzpostscript
-application/x-cb7
-application/x-cbr
-application/x-cbt
-application/x-cbz
-application/x-ccmx
-application/x-cd-image
-application/x-cdrdao-toc
-application/x-chess-pgn
-application/x-cisco-vpn-settings
-application/x-class-file
-application/x-compress
-application/x-compressed-tar
-application/x-core
-application/x-cpio
-application/x-cpio-compressed
-application/x-csh
-application/x-cue
-application/x-dar
-application/x-dbf
-application/x-dc-rom
-application/x-designer
-application/x-desktop
-application/x-dia-diagram
-application/x-dia-shape
-application/x-docbook+xml
-application/x-dvi
-application/x-e-theme
-application/x-egon
-application/x-executable
-application/x-fictionbook+xml
-application/x-fluid
-application/x-font-afm
-application/x-font-bdf
-application/x-font-dos
-application/x-font-framemaker
-application/x-font-libgrx
-application/x-font-linux-psf
-application/x-font-otf
-application/x-font-pcf
-application/x-font-speedo
-application/x-font-sunos-news
-application/x-font-tex
-application/x-font-tex-tfm
-application/x-font-ttf
-application/x-font-ttx
-application/x-font-type1
-application/x-font-vfont
-application/x-frame
-application/x-gameboy-rom
-application/x-gamecube-rom
-application/x-gba-rom
-application/x-gdbm
-application/x-gedcom
-application/x-genesis-rom
-application/x-gettext-translation
-application/x-glade
-application/x-gnucash
-application/x-gnumeric
-application/x-gnuplot
-application/x-go-sgf
-application/x-graphite
-application/x-gtk-builder
-application/x-gtktalog
-application/x-gz-font-linux-psf
-application/x-gzdvi
-application/x-gzpdf
-application/x-gzpostscript
-application/x-hdf
-application/x-hwp
-application/x-hwt
-application/x-ica
-application/x-iff
-application/x-ipod-firmware
-application/x-it87
-application/x-iwork-keynote-sffkey
-application/x-java
-application/x-java-archive
-application/x-java-jce-keystore
-application/x-java-jnlp-file
-application/x-java-keystore
-application/x-java-pack200
-application/x-jbuilder-project
-application/x-karbon
-application/x-kchart
-application/x-kexi-connectiondata
-application/x-kexiproject-shortcut
-application/x-kexiproject-sqlite2
-application/x-kexiproject-sqlite3
-application/x-kformula
-application/x-killustrator
-application/x-kivio
-application/x-kontour
-application/x-kpovmodeler
-application/x-kpresenter
-application/x-krita
-application/x-kspread
-application/x-kspread-crypt
-application/x-ksysv-package
-application/x-kugar
-application/x-kword
-application/x-kword-crypt
-application/x-lha
-application/x-lhz
-application/x-lrzip
-application/x-lrzip-compressed-tar
-application/x-lyx
-application/x-lz4
-application/x-lzip
-application/x-lzma
-application/x-lzma-compressed-tar
-application/x-lzop
-application/x-m4
-application/x-macbinary
-application/x-magicpoint
-application/x-markaby
-application/x-matroska
-application/x-mif
-application/x-mimearchive
-application/x-mobipocket-ebook
-application/x-mozilla-bookmarks
-application/x-ms-dos-executable
-application/x-ms-wim
-application/x-msi
-application/x-mswinurl
-application/x-mswrite
-application/x-msx-rom
-application/x-n64-rom
-application/x-nautilus-link
-application/x-navi-animation
-application/x-nes-rom
-application/x-netcdf
-application/x-netshow-channel
-application/x-nintendo-ds-rom
-application/x-nzb
-application/x-object
-application/x-ole-storage
-application/x-oleo
-application/x-pagemaker
-application/x-pak
-application/x-par2
-application/x-partial-download
-application/x-pc-engine-rom
-application/x-pef-executable
-application/x-perl
-application/x-php
-application/x-pkcs12
-application/x-pkcs7-certificates
-application/x-planperfect
-application/x-pocket-word
-application/x-profile
-application/x-pw
-application/x-python-bytecode
-application/x-qpress
-application/x-qtiplot
-application/x-quattropro
-application/x-quicktime-media-link
-application/x-qw
-application/x-rar
-application/x-raw-disk-image
-application/x-raw-disk-image-xz-compressed
-application/x-riff
-application/x-rpm
-application/x-ruby
-application/x-sami
-application/x-sc
-application/x-shar
-application/x-shared-library-la
-application/x-sharedlib
-application/x-shellscript
-application/x-shorten
-application/x-siag
-application/x-slp
-application/x-smaf
-application/x-sms-rom
-application/x-source-rpm
-application/x-spss-por
-application/x-spss-sav
-application/x-sqlite2
-application/x-sqlite3
-application/x-stuffit
-application/x-subrip
-application/x-sv4cpio
-application/x-sv4crc
-application/x-t602
-application/x-tar
-application/x-tarz
-application/x-tex-gf
-application/x-tex-pk
-application/x-tgif
-application/x-theme
-application/x-toutdoux
-application/x-trash
-application/x-trig
-application/x-troff-man
-application/x-troff-man-compressed
-application/x-tzo
-application/x-ufraw
-application/x-ustar
-application/x-wais-source
-application/x-wii-rom
-application/x-windows-themepack
-application/x-wpg
-application/x-wwf
-application/x-x509-ca-cert
-application/x-xbel
-application/x-xliff
-application/x-xpinstall
-application/x-xz
-application/x-xz-compressed-tar
-application/x-xzpdf
-application/x-yaml
-application/x-zerosize
-application/x-zip-compressed-fb2
-application/x-zoo
-application/xhtml+xml
-application/xml
-application/xml-dtd
-application/xml-external-parsed-entity
-application/xslt+xml
-application/xspf+xml
-application/zip
-application/zlib
-audio/AMR
-audio/AMR-WB
-audio/aac
-audio/ac3
-audio/annodex
-audio/basic
-audio/flac
-audio/midi
-audio/mp2
-audio/mp4
-audio/mpeg
-audio/ogg
-audio/prs.sid
-audio/vnd.dts
-audio/vnd.dts.hd
-audio/vnd.rn-realaudio
-audio/webm
-audio/x-adpcm
-audio/x-aifc
-audio/x-aiff
-audio/x-amzxml
-audio/x-ape
-audio/x-flac+ogg
-audio/x-gsm
-audio/x-iriver-pla
-audio/x-it
-audio/x-m4b
-audio/x-matroska
-audio/x-minipsf
-audio/x-mo3
-audio/x-mod
-audio/x-mpegurl
-audio/x-ms-asx
-audio/x-ms-wma
-audio/x-musepack
-audio/x-opus+ogg
-audio/x-psf
-audio/x-psflib
-audio/x-riff
-audio/x-s3m
-audio/x-scpls
-audio/x-speex
-audio/x-speex+ogg
-audio/x-stm
-audio/x-tta
-audio/x-voc
-audio/x-vorbis+ogg
-audio/x-wav
-audio/x-wavpack
-audio/x-wavpack-correction
-audio/x-xi
-audio/x-xm
-audio/x-xmf
-image/bmp
-image/cgm
-image/dpx
-image/fax-g3
-image/fits
-image/g3fax
-image/gif
-image/ief
-image/jp2
-image/jpeg
-image/openraster
-image/png
-image/rle
-image/svg+xml
-image/svg+xml-compressed
-image/tiff
-image/vnd.adobe.photoshop
-image/vnd.djvu
-image/vnd.dwg
-image/vnd.dxf
-image/vnd.microsoft.icon
-image/vnd.ms-modi
-image/vnd.rn-realpix
-image/vnd.wap.wbmp
-image/webp
-image/x-3ds
-image/x-adobe-dng
-image/x-applix-graphics
-image/x-bzeps
-image/x-canon-cr2
-image/x-canon-crw
-image/x-cmu-raster
-image/x-compressed-xcf
-image/x-dcraw
-image/x-dds
-image/x-dib
-image/x-emf
-image/x-eps
-image/x-exr
-image/x-fpx
-image/x-fuji-raf
-image/x-gzeps
-image/x-icns
-image/x-ilbm
-image/x-jng
-image/x-kodak-dcr
-image/x-kodak-k25
-image/x-kodak-kdc
-image/x-lwo
-image/x-lws
-image/x-macpaint
-image/x-minolta-mrw
-image/x-msod
-image/x-niff
-image/x-nikon-nef
-image/x-olympus-orf
-image/x-panasonic-raw
-image/x-panasonic-raw2
-image/x-pcx
-image/x-pentax-pef
-image/x-photo-cd
-image/x-pict
-image/x-portable-anymap
-image/x-portable-bitmap
-image/x-portable-graymap
-image/x-portable-pixmap
-image/x-quicktime
-image/x-rgb
-image/x-sgi
-image/x-sigma-x3f
-image/x-skencil
-image/x-sony-arw
-image/x-sony-sr2
-image/x-sony-srf
-image/x-sun-raster
-image/x-tga
-image/x-tiff-multipage
-image/x-win-bitmap
-image/x-wmf
-image/x-xbitmap
-image/x-xcf
-image/x-xcursor
-image/x-xfig
-image/x-xpixmap
-image/x-xwindowdump
-inode/blockdevice
-inode/chardevice
-inode/directory
-inode/fifo
-inode/mount-point
-inode/socket
-inode/symlink
-message/delivery-status
-message/disposition-notification
-message/external-body
-message/news
-message/partial
-message/rfc822
-message/x-gnu-rmail
-model/vrml
-multipart/alternative
-multipart/appledouble
-multipart/digest
-multipart/encrypted
-multipart/mixed
-multipart/related
-multipart/report
-multipart/signed
-multipart/x-mixed-replace
-text/cache-manifest
-text/calendar
-text/css
-text/csv
-text/enriched
-text/html
-text/htmlh
-text/markdown
-text/plain
-text/rfc822-headers
-text/richtext
-text/sgml
-text/spreadsheet
-text/tab-separated-values
-text/troff
-text/vcard
-text/vnd.graphviz
-text/vnd.rn-realtext
-text/vnd.sun.j2me.app-descriptor
-text/vnd.trolltech.linguist
-text/vnd.wap.wml
-text/vnd.wap.wmlscript
-text/vtt
-text/x-adasrc
-text/x-authors
-text/x-bibtex
-text/x-c++hdr
-text/x-c++src
-text/x-changelog
-text/x-chdr
-text/x-cmake
-text/x-cobol
-text/x-copying
-text/x-credits
-text/x-csharp
-text/x-csrc
-text/x-dcl
-text/x-dsl
-text/x-dsrc
-text/x-eiffel
-text/x-emacs-lisp
-text/x-erlang
-text/x-fortran
-text/x-genie
-text/x-gettext-translation
-text/x-gettext-translation-template
-text/x-go
-text/x-google-video-pointer
-text/x-haskell
-text/x-iMelody
-text/x-idl
-text/x-install
-text/x-iptables
-text/x-java
-text/x-ldif
-text/x-lilypond
-text/x-literate-haskell
-text/x-log
-text/x-lua
-text/x-makefile
-text/x-matlab
-text/x-microdvd
-text/x-moc
-text/x-modelica
-text/x-mof
-text/x-mpsub
-text/x-mrml
-text/x-ms-regedit
-text/x-mup
-text/x-nfo
-text/x-objcsrc
-text/x-ocaml
-text/x-ocl
-text/x-ooc
-text/x-opml+xml
-text/x-pascal
-text/x-patch
-text/x-python
-text/x-qml
-text/x-readme
-text/x-reject
-text/x-rpm-spec
-text/x-scala
-text/x-scheme
-text/x-scons
-text/x-setext
-text/x-ssa
-text/x-subviewer
-text/x-svhdr
-text/x-svsrc
-text/x-tcl
-text/x-tex
-text/x-texinfo
-text/x-troff-me
-text/x-troff-mm
-text/x-troff-ms
-text/x-txt2tags
-text/x-uil
-text/x-uri
-text/x-uuencode
-text/x-vala
-text/x-verilog
-text/x-vhdl
-text/x-xmi
-text/x-xslfo
-text/xmcd
-video/3gpp
-video/3gpp2
-video/annodex
-video/dv
-video/isivideo
-video/mp2t
-video/mp4
-video/mpeg
-video/ogg
-video/quicktime
-video/vnd.mpegurl
-video/vnd.rn-realvideo
-video/vnd.vivo
-video/wavelet
-video/webm
-video/x-anim
-video/x-flic
-video/x-flv
-video/x-javafx
-video/x-matroska
-video/x-matroska-3d
-video/x-mng
-video/x-ms-wmv
-video/x-msvideo
-video/x-nsv
-video/x-ogm+ogg
-video/x-sgi-movie
-video/x-theora+ogg
-x-content/audio-cdda
-x-content/audio-dvd
-x-content/audio-player
-x-content/blank-bd
-x-content/blank-cd
-x-content/blank-dvd
-x-content/blank-hddvd
-x-content/ebook-reader
-x-content/image-dcf
-x-content/image-picturecd
-x-content/software
-x-content/unix-software
-x-content/video-bluray
-x-content/video-dvd
-x-content/video-hddvd
-x-content/video-svcd
-x-content/video-vcd
-x-content/win32-software
-x-epoc/x-sisx-app
-`
-)
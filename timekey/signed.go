@@ -0,0 +1,64 @@
+package timekey
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+const (
+	macBits = 14
+	macMask = 1<<macBits - 1
+	// size classes of signed cookies, class c >= 1 is [2^(c+9), 2^(c+10))
+	minClassBits = 10
+	maxSizeClass = expMask
+)
+
+// Set Fid.Cookie like InsertCookie, but replaces the size mantissa with
+// 14 bits HMAC-SHA256 of (volume id, key, mime, size) under secret, so
+// fids can't be derived from time, mime and size alone. A random guess
+// still passes one time in 16384, which slows down enumeration but is no
+// access control by itself. Sizes are kept as powers of 2. The key must be
+// inserted before, and not changed after
+func (f *Fid) InsertSignedCookie(secret []byte, dataSize int, mimeType ...string) {
+	f.Cookie = cookieV1Mark | cookieSigned | mimeBits(mimeType) |
+		sizeClass(int64(dataSize))<<expShift
+	f.Cookie |= f.mac(secret)
+}
+
+// Verify the HMAC of signed cookie under secret, unsigned cookies never pass
+func (f *Fid) Verify(secret []byte) bool {
+	if f.CookieVersion() != CookieV1Signed {
+		return false
+	}
+	return f.Cookie&macMask == f.mac(secret)
+}
+
+// HMAC of volume id, key and the cookie without HMAC bits
+func (f *Fid) mac(secret []byte) uint32 {
+	b := make([]byte, 16)
+	binary.BigEndian.PutUint32(b, f.Id)
+	binary.BigEndian.PutUint64(b[4:], f.Key)
+	binary.BigEndian.PutUint32(b[12:], f.Cookie&^macMask)
+	h := hmac.New(sha256.New, secret)
+	h.Write(b)
+	return uint32(binary.BigEndian.Uint16(h.Sum(nil))) & macMask
+}
+
+// class 0 for sizes below 1 KB, sizes of 1 TB and above are the max class
+func sizeClass(size int64) uint32 {
+	c := uint32(0)
+	for size >= 1<<minClassBits && c < maxSizeClass {
+		size >>= 1
+		c++
+	}
+	return c
+}
+
+func decodeSizeClass(c uint32) (size int64, precision int64) {
+	if c == 0 {
+		return 0, 1 << minClassBits
+	}
+	size = 1 << (c + minClassBits - 1)
+	return size, size
+}
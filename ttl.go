@@ -0,0 +1,107 @@
+// time to live of files
+package weedo
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// units of TTL, the values are the same as seaweedfs
+const (
+	TTLEmpty = iota
+	TTLMinute
+	TTLHour
+	TTLDay
+	TTLWeek
+	TTLMonth
+	TTLYear
+)
+
+var ttlUnits = []byte{0, 'm', 'h', 'd', 'w', 'M', 'y'}
+
+// Time to live of a file or volume, e.g.: 3m, 4h, 5d, 6w, 7M, 8y
+type TTL struct {
+	Count uint8
+	Unit  uint8
+}
+
+// Parse TTL string as seaweedfs does, count without unit is in minutes
+func ParseTTL(s string) (ttl TTL, err error) {
+	if s == "" {
+		return
+	}
+	unit := s[len(s)-1]
+	count := s[:len(s)-1]
+	if unit >= '0' && unit <= '9' {
+		unit = 'm'
+		count = s
+	}
+	n, err := strconv.ParseUint(count, 10, 8)
+	if err != nil {
+		return ttl, errors.New("TTL format invalid: " + s)
+	}
+	for i, u := range ttlUnits {
+		if i > 0 && u == unit {
+			ttl.Count, ttl.Unit = uint8(n), uint8(i)
+			if n == 0 {
+				ttl = TTL{}
+			}
+			return
+		}
+	}
+	return ttl, errors.New("TTL unit invalid: " + s)
+}
+
+// Is ttl empty, which means never expire
+func (t TTL) IsEmpty() bool {
+	return t.Count == 0 || t.Unit == TTLEmpty || int(t.Unit) >= len(ttlUnits)
+}
+
+// TTL in string form, empty for never expire
+func (t TTL) String() string {
+	if t.IsEmpty() {
+		return ""
+	}
+	return strconv.Itoa(int(t.Count)) + string(ttlUnits[t.Unit])
+}
+
+// TTL in duration, months are 30 days and years are 365 days
+func (t TTL) Duration() time.Duration {
+	if t.IsEmpty() {
+		return 0
+	}
+	d := []time.Duration{0, time.Minute, time.Hour, 24 * time.Hour,
+		7 * 24 * time.Hour, 30 * 24 * time.Hour, 365 * 24 * time.Hour}[t.Unit]
+	return time.Duration(t.Count) * d
+}
+
+func (t TTL) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+func (t *TTL) UnmarshalText(b []byte) (err error) {
+	*t, err = ParseTTL(string(b))
+	return
+}
+
+// Volume status of seaweedfs reports TTL as {"Count":3,"Unit":1} or as
+// count<<8|unit, both are accepted besides the string form
+func (t *TTL) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		return t.UnmarshalText([]byte(s))
+	}
+	var n uint32
+	if err := json.Unmarshal(b, &n); err == nil {
+		*t = TTL{Count: uint8(n >> 8), Unit: uint8(n)}
+		return nil
+	}
+	var v struct{ Count, Unit uint8 }
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	*t = TTL{Count: v.Count, Unit: v.Unit}
+	return nil
+}
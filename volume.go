@@ -6,15 +6,22 @@ import (
 	"errors"
 	"io"
 	"log"
+	"mime"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 type Volume struct {
 	Url       string
 	PublicUrl string
+
+	mu      sync.Mutex
+	ttls    map[uint64]TTL // ttl of volumes on this server
+	checked time.Time      // when ttls were last checked for an unknown volume
 }
 
 func NewVolume(url, publicUrl string) *Volume {
@@ -30,39 +37,153 @@ func NewVolume(url, publicUrl string) *Volume {
 	}
 }
 
+// Optional parameters for uploading a file
+type UploadOption struct {
+	Version int // upload as fid_Version if > 0
+	Jwt     Jwt // token for volume servers secured by jwt.signing.key
+	Ttl     TTL // time to live of the file
+	// where to assign fids, used by Client and Master.Submit
+	Collection  string
+	Replication string
+	// split files larger than it into chunks with a chunk manifest, used by Client
+	MaxChunkSize int64
+	manifest     bool // upload a chunk manifest
+	// custom metadata sent as Seaweed-<name> headers, and returned by reads
+	// in FileInfo.Pairs. Names are canonicalized like http header keys
+	Pairs map[string]string
+	// compare md5 or crc of content with the etag returned by volume server,
+	// a *ChecksumError is returned if they don't match
+	Verify bool
+	// compress files of compressible mime types, files uploaded in chunks
+	// by Client are not compressed
+	Compression *Compression
+}
+
+// prefix of headers kept by volume server as metadata pairs of a file
+const pairNamePrefix = "Seaweed-"
+
+func (opt *UploadOption) setHeader(h http.Header) {
+	opt.Jwt.setHeader(h)
+	opt.setPairs(h)
+}
+
+func (opt *UploadOption) setPairs(h http.Header) {
+	for k, v := range opt.Pairs {
+		h.Set(pairNamePrefix+k, v)
+	}
+}
+
+// assign option with Collection, Replication and Ttl
+func (opt *UploadOption) assignOption() *AssignOption {
+	return &AssignOption{
+		Collection:  opt.Collection,
+		Replication: opt.Replication,
+		Ttl:         opt.Ttl,
+	}
+}
+
+// query string of upload url
+func (opt *UploadOption) query() string {
+	if opt == nil {
+		return ""
+	}
+	v := url.Values{}
+	if len(opt.Collection) > 0 {
+		v.Set("collection", opt.Collection)
+	}
+	if len(opt.Replication) > 0 {
+		v.Set("replication", opt.Replication)
+	}
+	if !opt.Ttl.IsEmpty() {
+		v.Set("ttl", opt.Ttl.String())
+	}
+	if opt.manifest {
+		v.Set("cm", "true")
+	}
+	if len(v) == 0 {
+		return ""
+	}
+	return "?" + v.Encode()
+}
+
 // Upload File
 func (v *Volume) Upload(fid string, filename, mimeType string, file io.Reader, version ...int) (size int64, err error) {
+	opt := new(UploadOption)
+	if len(version) > 0 {
+		opt.Version = version[0]
+	}
+	return v.UploadWithOption(fid, filename, mimeType, file, opt)
+}
+
+// Upload File with optional parameters
+func (v *Volume) UploadWithOption(fid string, filename, mimeType string, file io.Reader, opt *UploadOption) (size int64, err error) {
+	if opt == nil {
+		opt = new(UploadOption)
+	}
 	url := v.Url + "/" + fid
-	if len(version) > 0 && version[0] > 0 {
-		url = url + "_" + strconv.Itoa(version[0])
+	if opt.Version > 0 {
+		url = url + "_" + strconv.Itoa(opt.Version)
 	}
+	url = url + opt.query()
 
-	formData, contentType, err := makeFormData(filename, mimeType, file)
+	var h, hc *hasher
+	if opt.Verify {
+		h = newHasher()
+		file = io.TeeReader(file, h)
+	}
+	var encoding string
+	if !opt.manifest {
+		var content io.ReadCloser
+		if content, encoding, err = opt.Compression.compress(mimeType, file); err != nil {
+			return
+		}
+		defer content.Close()
+		file = content
+		// volume servers may compute the etag of either content
+		if opt.Verify && encoding != "" {
+			hc = newHasher()
+			file = io.TeeReader(file, hc)
+		}
+	}
+	formData, contentType, err := makeFormData(filename, mimeType, encoding, file)
 	if err != nil {
 		return
 	}
 
-	resp, err := upload(url, contentType, formData)
-	if err == nil {
-		size = resp.Size
+	header := make(http.Header)
+	opt.setHeader(header)
+	resp, err := upload(url, contentType, formData, header)
+	if err != nil {
+		return
+	}
+	size = resp.Size
+	if h != nil {
+		err = h.checkETag(fid, resp.ETag)
+		if err != nil && hc != nil && hc.checkETag(fid, resp.ETag) == nil {
+			err = nil
+		}
 	}
 
 	return
 }
 
 // Delete File
-func (v *Volume) Delete(fid string, count int) (err error) {
+func (v *Volume) Delete(fid string, count int, jwt ...Jwt) (err error) {
 	if count <= 0 {
 		count = 1
 	}
+	header := make(http.Header)
+	if len(jwt) > 0 {
+		jwt[0].setHeader(header)
+	}
 
 	url := v.Url + "/" + fid
-	if err := del(url); err != nil {
+	if err := del(url, header); err != nil {
 		return err
 	}
 
 	for i := 1; i < count; i++ {
-		if err := del(url + "_" + strconv.Itoa(i)); err != nil {
+		if err := del(url+"_"+strconv.Itoa(i), header); err != nil {
 			log.Println(err)
 		}
 	}
@@ -70,6 +191,186 @@ func (v *Volume) Delete(fid string, count int) (err error) {
 	return nil
 }
 
+// Conditions of a read, the file is not read if it matches all of them
+type Conditions struct {
+	IfNoneMatch     string    // etag of a copy, as FileInfo.ETag
+	IfModifiedSince time.Time // last modified time of a copy
+}
+
+func (c *Conditions) setHeader(h http.Header) {
+	if c == nil {
+		return
+	}
+	if etag := c.IfNoneMatch; etag != "" {
+		if etag != "*" && !strings.HasSuffix(etag, `"`) {
+			etag = `"` + etag + `"`
+		}
+		h.Set("If-None-Match", etag)
+	}
+	if !c.IfModifiedSince.IsZero() {
+		h.Set("If-Modified-Since", c.IfModifiedSince.UTC().Format(http.TimeFormat))
+	}
+}
+
+// Information of a file returned by volume server
+type FileInfo struct {
+	Name         string
+	MimeType     string
+	Size         int64
+	ETag         string
+	LastModified time.Time
+	Ttl          TTL // ttl of the volume the file belongs to
+	Pairs        map[string]string
+}
+
+func newFileInfo(resp *http.Response) *FileInfo {
+	info := &FileInfo{
+		MimeType: resp.Header.Get("Content-Type"),
+		Size:     resp.ContentLength,
+		ETag:     strings.Trim(resp.Header.Get("Etag"), `"`),
+	}
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil {
+		info.Name = params["filename"]
+	}
+	if t, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		info.LastModified = t
+	}
+	for k, v := range resp.Header {
+		if strings.HasPrefix(k, pairNamePrefix) && len(v) > 0 {
+			if info.Pairs == nil {
+				info.Pairs = make(map[string]string)
+			}
+			info.Pairs[k[len(pairNamePrefix):]] = v[0]
+		}
+	}
+	return info
+}
+
+// Download File, the caller must close the returned body. Files stored
+// compressed are decompressed, see RegisterCompressor
+func (v *Volume) Get(fid string, jwt ...Jwt) (body io.ReadCloser, info *FileInfo, err error) {
+	return v.GetIf(fid, nil, jwt...)
+}
+
+// Download File unless it matches cond, an error satisfying IsNotModified
+// is returned then. The caller must close the returned body
+func (v *Volume) GetIf(fid string, cond *Conditions, jwt ...Jwt) (body io.ReadCloser, info *FileInfo, err error) {
+	var j Jwt
+	if len(jwt) > 0 {
+		j = jwt[0]
+	}
+	resp, err := v.get(fid, j, cond, false)
+	if err != nil {
+		return
+	}
+	return resp.Body, v.fileInfo(fid, resp), nil
+}
+
+// Information of File without reading its content
+func (v *Volume) Head(fid string, jwt ...Jwt) (*FileInfo, error) {
+	header := make(http.Header)
+	if len(jwt) > 0 {
+		jwt[0].setHeader(header)
+	}
+	resp, err := head(v.Url+"/"+fid, header)
+	if err != nil {
+		return nil, err
+	}
+	return v.fileInfo(fid, resp), nil
+}
+
+// GET fid with its body decompressed, and verified by the etag if verify is
+// true. The etag is of the stored content, so compressed bytes are verified
+// before they are decompressed
+func (v *Volume) get(fid string, jwt Jwt, cond *Conditions, verify bool) (*http.Response, error) {
+	header := make(http.Header)
+	jwt.setHeader(header)
+	cond.setHeader(header)
+	acceptEncoding(header)
+	resp, err := get(v.Url+"/"+fid, header)
+	if err != nil {
+		return nil, err
+	}
+	if verify {
+		resp.Body = verifyBody(fid, resp)
+	}
+	return resp, decodeBody(resp)
+}
+
+func (v *Volume) fileInfo(fid string, resp *http.Response) *FileInfo {
+	info := newFileInfo(resp)
+	if id, e := strconv.ParseUint(strings.Split(fid, ",")[0], 10, 32); e == nil {
+		info.Ttl = v.ttl(id)
+	}
+	return info
+}
+
+// Result of deleting a fid
+type DeleteResult struct {
+	Fid    string `json:"fid"`
+	Size   int64  `json:"size"`
+	Status int    `json:"status"` // http status of the delete
+	Error  string `json:"error,omitempty"`
+}
+
+// Batch delete is not supported by the volume server
+var ErrBatchDeleteUnsupported = errors.New("batch delete not supported")
+
+// Delete files in one request by POST /delete, fids are not checked with
+// jwt but the volume server white list
+func (v *Volume) BatchDelete(fids []string) ([]*DeleteResult, error) {
+	values := url.Values{}
+	for _, fid := range fids {
+		values.Add("fid", fid)
+	}
+	resp, err := http.PostForm(v.Url+"/delete", values)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusBadRequest:
+		return nil, ErrBatchDeleteUnsupported
+	}
+	results := []*DeleteResult{}
+	if err = decodeJson(resp.Body, &results); err != nil {
+		return nil, ErrBatchDeleteUnsupported
+	}
+	if len(results) != len(fids) {
+		return nil, errors.New("batch delete: " + strconv.Itoa(len(results)) +
+			" results for " + strconv.Itoa(len(fids)) + " fids")
+	}
+
+	return results, nil
+}
+
+// Delete a single fid and report the result
+func (v *Volume) deleteOne(fid string, jwt Jwt) *DeleteResult {
+	r := &DeleteResult{Fid: fid}
+	request, err := http.NewRequest("DELETE", v.Url+"/"+fid, nil)
+	if err != nil {
+		r.Error = err.Error()
+		return r
+	}
+	jwt.setHeader(request.Header)
+	resp, err := http.DefaultClient.Do(request)
+	if err != nil {
+		r.Error = err.Error()
+		return r
+	}
+	defer resp.Body.Close()
+
+	r.Status = resp.StatusCode
+	ret := new(DeleteResult)
+	decodeJson(resp.Body, ret)
+	r.Size, r.Error = ret.Size, ret.Error
+	if r.Error == "" && (r.Status < 200 || r.Status > 299) {
+		r.Error = resp.Status
+	}
+	return r
+}
+
 func (v *Volume) AssignVolume(volumeId uint64, replica string) error {
 	values := url.Values{}
 	values.Set("volume", strconv.FormatUint(volumeId, 10))
@@ -81,16 +382,18 @@ func (v *Volume) AssignVolume(volumeId uint64, replica string) error {
 	return err
 }
 
-type volumeStatus struct {
+type VolumeStatus struct {
 	Version string
-	volumes []volume
+	Volumes []VolumeInfo
 	Error   string
 }
 
-type volume struct {
+type VolumeInfo struct {
 	Id               uint64
 	Size             uint64
 	RepType          string
+	Collection       string
+	Ttl              TTL
 	Version          int
 	FileCount        uint64
 	DeleteCount      uint64
@@ -98,8 +401,14 @@ type volume struct {
 	ReadOnly         bool
 }
 
-// Check Volume Server Status
+// Check Volume Server Status, see VolumeStatus for the status itself
 func (v *Volume) Status() (err error) {
+	_, err = v.VolumeStatus()
+	return
+}
+
+// Status of volume server and its volumes
+func (v *Volume) VolumeStatus() (status *VolumeStatus, err error) {
 	url := v.Url
 	if !strings.HasPrefix(url, "http://") {
 		url = "http://" + url
@@ -111,17 +420,48 @@ func (v *Volume) Status() (err error) {
 
 	defer resp.Body.Close()
 
-	status := new(volumeStatus)
+	status = new(VolumeStatus)
 	decoder := json.NewDecoder(resp.Body)
 	if err = decoder.Decode(status); err != nil {
 		log.Println(err)
-		return
+		return nil, err
 	}
 
 	if status.Error != "" {
 		err = errors.New(status.Error)
 		log.Println(err)
-		return
+		return nil, err
+	}
+
+	v.mu.Lock()
+	v.ttls = make(map[uint64]TTL)
+	for _, vol := range status.Volumes {
+		v.ttls[vol.Id] = vol.Ttl
 	}
+	v.mu.Unlock()
 	return
 }
+
+// volumes unknown to the server are checked again after it
+const ttlRecheck = time.Minute
+
+// ttl of volume, volume server status is checked if the volume is unknown,
+// at most once per ttlRecheck
+func (v *Volume) ttl(volumeId uint64) TTL {
+	v.mu.Lock()
+	ttl, ok := v.ttls[volumeId]
+	if ok || time.Since(v.checked) < ttlRecheck {
+		v.mu.Unlock()
+		return ttl
+	}
+	v.checked = time.Now()
+	v.mu.Unlock()
+	if status, err := v.VolumeStatus(); err == nil {
+		for _, vol := range status.Volumes {
+			if vol.Id == volumeId {
+				return vol.Ttl
+			}
+		}
+	}
+	return ttl
+}
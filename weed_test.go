@@ -2,8 +2,28 @@
 package weedo
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/Archs/weedo/internal/fakeweed"
 )
 
 var (
@@ -126,6 +146,25 @@ func TestFilerUpload(t *testing.T) {
 	}
 }
 
+func TestFilerUploadOption(t *testing.T) {
+	s := fakeweed.New(nil)
+	defer s.Close()
+	opt := &UploadOption{MaxChunkSize: 3<<20 + 1, Pairs: map[string]string{"Owner": "42"}, Collection: "docs"}
+	if err := NewFiler(s.URL).UploadWithOption("/a/hello.txt", "text/plain", strings.NewReader("hello"), opt); err != nil {
+		t.Fatal(err)
+	}
+	if s.Files["a/hello.txt"] != "hello" || len(s.Uploads) != 1 {
+		t.Fatal("not uploaded:", s.Files)
+	}
+	r := s.Uploads[0]
+	if q := r.URL.Query(); q.Get("maxMB") != "4" || q.Get("collection") != "docs" {
+		t.Error("upload query:", r.URL.RawQuery)
+	}
+	if r.Header.Get("Seaweed-Owner") != "42" {
+		t.Error("pairs not sent:", r.Header)
+	}
+}
+
 func TestFilerDelete(t *testing.T) {
 	if err := client.Filer("localhost:8888").Delete("text/"); err != nil {
 		t.Fatal(err)
@@ -140,3 +179,797 @@ func TestFilerDir(t *testing.T) {
 
 	t.Log(dir)
 }
+
+func TestGenJwt(t *testing.T) {
+	jwt := GenJwt("secret", 10, "3,01637037d6_1")
+	parts := strings.Split(string(jwt), ".")
+	if len(parts) != 3 {
+		t.Fatal("jwt malformed", jwt)
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := new(jwtClaims)
+	if err := json.Unmarshal(payload, claims); err != nil {
+		t.Fatal(err)
+	}
+	if claims.Fid != "3,01637037d6" || claims.Exp <= 0 {
+		t.Error("jwt claims not match", claims)
+	}
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if base64.RawURLEncoding.EncodeToString(mac.Sum(nil)) != parts[2] {
+		t.Error("jwt signature not match")
+	}
+	if GenJwt("", 10, "3,01637037d6") != "" {
+		t.Error("jwt should be empty without signing key")
+	}
+}
+
+func TestJwtReadsDeletes(t *testing.T) {
+	s := fakeweed.New(map[string]string{"3,0112345678": "a", "3,0212345678": "b"})
+	defer s.Close()
+	c := NewClient(s.URL)
+	c.SetJwtSigningKey("write", "read", 0)
+	body, _, err := c.Get("3,0112345678")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body.Close()
+	if _, err := c.Head("3,0112345678"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Delete("3,0112345678", 1); err != nil {
+		t.Fatal(err)
+	}
+	if r := c.DeleteMany([]string{"3,0212345678"}); r[0].Error != "" {
+		t.Fatal(r[0].Error)
+	}
+	for req, key := range map[string]string{
+		"GET /3,0112345678": "read", "HEAD /3,0112345678": "read",
+		"DELETE /3,0112345678": "write", "DELETE /3,0212345678": "write",
+	} {
+		fid := req[strings.Index(req, "/")+1:]
+		if auth := s.Auths[req]; auth != "Bearer "+string(GenJwt(key, 0, fid)) {
+			t.Errorf("%s: jwt %q not signed by the %s key", req, auth, key)
+		}
+	}
+}
+
+func TestGet(t *testing.T) {
+	file, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fid, _, err := client.AssignUpload(filename, "text/plain", file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, info, err := client.Get(fid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log("get", fid, info.Name, info.MimeType, len(data))
+}
+
+func TestTTL(t *testing.T) {
+	for s, want := range map[string]string{
+		"": "", "3m": "3m", "5": "5m", "4h": "4h", "1d": "1d",
+		"2w": "2w", "7M": "7M", "1y": "1y", "0d": "",
+	} {
+		ttl, err := ParseTTL(s)
+		if err != nil {
+			t.Fatal(s, err)
+		}
+		if ttl.String() != want {
+			t.Errorf("ParseTTL(%q) = %q, want %q", s, ttl, want)
+		}
+	}
+	for _, s := range []string{"m", "3x", "256d", "-1h"} {
+		if _, err := ParseTTL(s); err == nil {
+			t.Errorf("ParseTTL(%q) should fail", s)
+		}
+	}
+	var ttls []TTL
+	if err := json.Unmarshal([]byte(`["3h", {"Count":2,"Unit":3}, 1025, null]`), &ttls); err != nil {
+		t.Fatal(err)
+	}
+	if ttls[0].String() != "3h" || ttls[1].String() != "2d" || ttls[2].String() != "4m" || !ttls[3].IsEmpty() {
+		t.Error("TTL json not match", ttls)
+	}
+	if ttls[1].Duration() != 48*time.Hour {
+		t.Error("TTL duration not match", ttls[1].Duration())
+	}
+}
+
+func TestAssignUploadTTL(t *testing.T) {
+	file, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ttl, _ := ParseTTL("3m")
+	fid, _, err := client.AssignUploadWithOption(filename, "text/plain", file, &UploadOption{Ttl: ttl})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, info, err := client.Get(fid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body.Close()
+	if info.Ttl != ttl {
+		t.Error("ttl not match", info.Ttl)
+	}
+}
+
+func TestUploadPairs(t *testing.T) {
+	file, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pairs := map[string]string{"Owner": "42", "Source-Url": "http://example.com/hello.txt"}
+	fid, _, err := client.AssignUploadWithOption(filename, "text/plain", file, &UploadOption{Pairs: pairs})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, info, err := client.Get(fid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body.Close()
+	for k, v := range pairs {
+		if info.Pairs[k] != v {
+			t.Errorf("pair %s = %q, want %q", k, info.Pairs[k], v)
+		}
+	}
+}
+
+func TestDeleteMany(t *testing.T) {
+	fids := []string{}
+	for i := 0; i < 3; i++ {
+		file, err := os.Open(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fid, _, err := client.AssignUpload(filename, "text/plain", file)
+		file.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		fids = append(fids, fid)
+	}
+	fids = append(fids, "malformed")
+	results := client.DeleteMany(fids)
+	for i, r := range results {
+		if r.Fid != fids[i] {
+			t.Error("result order not match", r.Fid, fids[i])
+		}
+		if (r.Error != "") != (i == len(fids)-1) {
+			t.Error("unexpected result", r)
+		}
+	}
+}
+
+func TestDeleteManyVolumes(t *testing.T) {
+	for _, batch := range []bool{false, true} {
+		s := fakeweed.New(map[string]string{"3,0112345678": "a", "3,0212345678": "b"})
+		defer s.Close()
+		s4 := fakeweed.New(map[string]string{"4,0312345678": "c"})
+		defer s4.Close()
+		s.Volumes["4"] = s4.Host()
+		s.BatchDelete, s4.BatchDelete = batch, batch
+
+		fids := []string{"3,0112345678", "4,0312345678", "3,0412345678", "9,0512345678", "malformed", "3,0212345678"}
+		results := NewClient(s.URL).DeleteMany(fids)
+		if len(results) != len(fids) {
+			t.Fatal("results:", len(results))
+		}
+		for i, r := range results {
+			if r.Fid != fids[i] {
+				t.Error("result order not match", r.Fid, fids[i])
+			}
+			// missing, not looked up and malformed
+			if failed := i >= 2 && i <= 4; (r.Error != "") != failed {
+				t.Errorf("batch %v: unexpected result %+v", batch, r)
+			}
+		}
+		if results[2].Status != http.StatusNotFound {
+			t.Error("status of missing fid:", results[2].Status)
+		}
+		if s.Deletes != 2 || s4.Deletes != 1 || len(s.Files) != 0 || len(s4.Files) != 0 {
+			t.Errorf("batch %v: deleted %d and %d", batch, s.Deletes, s4.Deletes)
+		}
+	}
+}
+
+func TestSpool(t *testing.T) {
+	data := strings.Repeat("weedo", 100)
+	for _, max := range []int64{1000, 500, 10} {
+		// io.MultiReader hides the size of strings.Reader
+		sp, err := spool(io.MultiReader(strings.NewReader(data)), max, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sp.size != int64(len(data)) || (sp.file != nil) != (max < int64(len(data))) {
+			t.Error("spooled size not match", max, sp.size, sp.file)
+		}
+		b, err := ioutil.ReadAll(sp)
+		if err != nil || string(b) != data {
+			t.Error("spooled data not match", max, err)
+		}
+		sp.Close()
+		if sp.file != nil {
+			if _, err := os.Stat(sp.file.Name()); !os.IsNotExist(err) {
+				t.Error("temp file not removed", sp.file.Name())
+			}
+		}
+	}
+	sp, err := spool(strings.NewReader(data), 10, "")
+	if err != nil || sp.size != int64(len(data)) || sp.file != nil {
+		t.Error("size of strings.Reader should be known", err)
+	}
+}
+
+func TestAssignUploadTKStream(t *testing.T) {
+	pr, pw := io.Pipe()
+	go func() {
+		fmt.Fprint(pw, "hello, world")
+		pw.Close()
+	}()
+	fid, err := client.AssignUploadTK("stream.txt", pr, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := ParseFid(fid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size, _ := f.Size(); size != 12 {
+		t.Error("size in cookie not match", size)
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	data := "weedo"
+	h := newHasher()
+	io.WriteString(h, data)
+	sum := h.sum()
+	if sum.MD5 != fmt.Sprintf("%x", md5.Sum([]byte(data))) || sum.SHA256 != fmt.Sprintf("%x", sha256.Sum256([]byte(data))) {
+		t.Fatal("checksum:", sum)
+	}
+	for etag, ok := range map[string]bool{
+		`"` + sum.MD5 + `"`:      true,
+		strings.ToUpper(sum.MD5): true,
+		fmt.Sprintf("%08x", crc32.Checksum([]byte(data), crc32c)): true,
+		"00000000":              false,
+		strings.Repeat("0", 32): false,
+		"unknown":               true,
+	} {
+		if err := h.checkETag("3,01", etag); (err == nil) != ok {
+			t.Error("etag", etag, err)
+		}
+	}
+	for _, expected := range []string{sum.MD5, sum.SHA256, "md5:" + sum.MD5, "SHA256:" + strings.ToUpper(sum.SHA256)} {
+		if err := h.check("3,01", expected); err != nil {
+			t.Error(err)
+		}
+	}
+	if err, ok := h.check("3,01", strings.Repeat("0", 64)).(*ChecksumError); !ok || err.Actual != sum.SHA256 {
+		t.Error("sha256 mismatch not reported", err)
+	}
+
+	resp := &http.Response{StatusCode: 200, Header: http.Header{"Etag": {`"` + sum.MD5 + `"`}},
+		Body: ioutil.NopCloser(strings.NewReader("weed0"))}
+	if _, err := ioutil.ReadAll(verifyBody("3,01", resp)); err == nil {
+		t.Error("corrupt read not reported")
+	}
+	resp.Body = ioutil.NopCloser(strings.NewReader(data))
+	if b, err := ioutil.ReadAll(verifyBody("3,01", resp)); err != nil || string(b) != data {
+		t.Error("verified read:", string(b), err)
+	}
+	resp.Header.Set("X-File-Store", "chunked")
+	resp.Body = ioutil.NopCloser(strings.NewReader("weed0"))
+	if _, err := ioutil.ReadAll(verifyBody("3,01", resp)); err != nil {
+		t.Error("chunked files should not be verified by etag", err)
+	}
+}
+
+func TestChecksumCompressed(t *testing.T) {
+	data := strings.Repeat("verify me ", 100)
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write([]byte(data))
+	w.Close()
+	stored := buf.Bytes()
+	sum := md5.Sum(stored)
+	etag := hex.EncodeToString(sum[:])
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/dir/lookup" {
+			host := r.Host
+			fmt.Fprintf(w, `{"locations":[{"url":"%s","publicUrl":"%s"}]}`, host, host)
+			return
+		}
+		// the etag is of the stored content
+		w.Header().Set("Etag", `"`+etag+`"`)
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(stored)
+	}))
+	defer s.Close()
+	c := NewClient(s.URL)
+	plain := sha256.Sum256([]byte(data))
+	if err := c.Verify("3,01637037d6", hex.EncodeToString(plain[:])); err != nil {
+		t.Error("verify compressed:", err)
+	}
+	c.VerifyReads(true)
+	body, _, err := c.Get("3,01637037d6")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(body)
+	body.Close()
+	if err != nil || string(b) != data {
+		t.Error("verified read:", err)
+	}
+
+	etag = strings.Repeat("0", 32)
+	if _, ok := c.Verify("3,01637037d6", "").(*ChecksumError); !ok {
+		t.Error("compressed content not matching the etag not reported")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	data := "verify me"
+	fid, _, sum, err := client.AssignUploadSum("verify.txt", "text/plain", strings.NewReader(data), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = client.Verify(fid, sum.SHA256); err != nil {
+		t.Error(err)
+	}
+	if err = client.Verify(fid, "md5:"+sum.MD5); err != nil {
+		t.Error(err)
+	}
+	if _, ok := client.Verify(fid, strings.Repeat("0", 64)).(*ChecksumError); !ok {
+		t.Error("mismatch not reported")
+	}
+	client.Delete(fid, 1)
+	results := client.Scrub([]ScrubEntry{{Fid: fid, Size: -1}}, 1)
+	if results[0].Status != ScrubMissing {
+		t.Error("deleted file should be missing:", results[0])
+	}
+}
+
+func TestEncrypt(t *testing.T) {
+	keys, err := NewStaticKeyProvider("k1", make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dataKey := []byte(strings.Repeat("d", 32))
+	keyId, wrapped, err := keys.WrapKey(dataKey)
+	if err != nil || keyId != "k1" {
+		t.Fatal(keyId, err)
+	}
+	// rotate, keys rotated out still unwrap
+	rotated, _ := NewStaticKeyProvider("k2", []byte(strings.Repeat("k", 16)))
+	if _, err = rotated.UnwrapKey(keyId, wrapped); err == nil {
+		t.Error("unknown key id should fail")
+	}
+	rotated.AddKey("k1", make([]byte, 32))
+	if k, err := rotated.UnwrapKey(keyId, wrapped); err != nil || string(k) != string(dataKey) {
+		t.Error("unwrap:", err)
+	}
+	wrapped[len(wrapped)-1] ^= 1
+	if _, err = keys.UnwrapKey(keyId, wrapped); err != ErrDecrypt {
+		t.Error("corrupt wrapped key:", err)
+	}
+
+	c := NewEncryptingClient(client, keys)
+	aead, _ := newGCM(dataKey)
+	nonce := make([]byte, 12)
+	const chunkSize = 16
+	for _, size := range []int{0, 1, chunkSize, chunkSize + 1, 5 * chunkSize, 5*chunkSize + 7} {
+		data := strings.Repeat("x", size)
+		e := &encryptingReader{r: bufio.NewReader(strings.NewReader(data)), aead: aead, nonce: nonce, buf: make([]byte, chunkSize)}
+		sealed, err := ioutil.ReadAll(e)
+		if err != nil || e.n != int64(size) {
+			t.Fatal(size, e.n, err)
+		}
+		if n := plainSize(int64(len(sealed)), chunkSize, aead.Overhead()); n != int64(size) {
+			t.Error("plain size of", size, n)
+		}
+		info := &FileInfo{Size: int64(len(sealed)), Pairs: map[string]string{
+			encAlgPair:   encAlg,
+			encKeyIdPair: keyId,
+			encKeyPair:   base64.StdEncoding.EncodeToString(func() []byte { _, w, _ := keys.WrapKey(dataKey); return w }()),
+			encNoncePair: base64.StdEncoding.EncodeToString(nonce),
+			encChunkPair: strconv.Itoa(chunkSize),
+		}}
+		open := func(b []byte) (string, error) {
+			r, err := c.decrypter(ioutil.NopCloser(bytes.NewReader(b)), info)
+			if err != nil {
+				return "", err
+			}
+			plain, err := ioutil.ReadAll(r)
+			return string(plain), err
+		}
+		if plain, err := open(sealed); err != nil || plain != data || info.Size != int64(size) {
+			t.Error("decrypt", size, err, info.Size)
+		}
+		// truncated, reordered or modified content
+		bad := [][]byte{sealed[:len(sealed)-1], append([]byte{}, sealed...)}
+		bad[1][0] ^= 1
+		if size > chunkSize {
+			n := chunkSize + aead.Overhead()
+			bad = append(bad, sealed[:n], append(append([]byte{}, sealed[n:2*n]...), sealed[:n]...))
+		}
+		for i, b := range bad {
+			if _, err := open(b); err != ErrDecrypt {
+				t.Error("bad content", size, i, err)
+			}
+		}
+		// chunk size of the pairs is authenticated, and capped before it's allocated
+		for _, chunk := range []int{2 * chunkSize, MaxEncryptChunkSize + 1} {
+			info.Pairs[encChunkPair] = strconv.Itoa(chunk)
+			if _, err := open(sealed); err == nil {
+				t.Error("chunk size modified", size, chunk)
+			}
+		}
+	}
+	if _, err = c.decrypter(ioutil.NopCloser(strings.NewReader("plain")), &FileInfo{}); err != ErrNotEncrypted {
+		t.Error("plain file:", err)
+	}
+}
+
+func TestEncryptingClient(t *testing.T) {
+	keys, _ := NewStaticKeyProvider("k1", make([]byte, 32))
+	c := NewEncryptingClient(client, keys)
+	c.SetChunkSize(4)
+	data := "secret of weedo"
+	fid, size, err := c.AssignUploadWithOption("secret.txt", "text/plain", strings.NewReader(data), &UploadOption{Pairs: map[string]string{"Owner": "weedo"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Delete(fid, 1)
+	if size != int64(len(data)) {
+		t.Error("size:", size)
+	}
+	body, info, err := client.Get(fid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, _ := ioutil.ReadAll(body)
+	body.Close()
+	if strings.Contains(string(stored), "secret") || info.Pairs["Owner"] != "weedo" {
+		t.Error("stored content:", string(stored), info.Pairs)
+	}
+	body, info, err = c.Get(fid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+	b, err := ioutil.ReadAll(body)
+	if err != nil || string(b) != data || info.Size != int64(len(data)) {
+		t.Error("decrypted:", string(b), err, info.Size)
+	}
+}
+
+func TestCompression(t *testing.T) {
+	c := &Compression{Encoding: "gzip", MinSize: 10}
+	for mimeType, ok := range map[string]bool{
+		"text/plain; charset=utf-8": true,
+		"application/json":          true,
+		"application/ld+json":       true,
+		"image/svg+xml":             true,
+		"image/png":                 false,
+		"":                          false,
+	} {
+		if c.compressible(mimeType) != ok {
+			t.Error("compressible", mimeType)
+		}
+	}
+	if (&Compression{MimeTypes: []string{"image/*"}}).compressible("text/plain") {
+		t.Error("mime types of the policy should replace the default")
+	}
+
+	data := strings.Repeat("compress me ", 100)
+	for _, r := range []io.Reader{strings.NewReader(data), ioutil.NopCloser(strings.NewReader(data))} {
+		content, encoding, err := c.compress("text/plain", r)
+		if err != nil || encoding != "gzip" {
+			t.Fatal(encoding, err)
+		}
+		compressed, err := ioutil.ReadAll(content)
+		if err != nil || len(compressed) >= len(data) {
+			t.Fatal("compressed size", len(compressed), err)
+		}
+		resp := &http.Response{Header: http.Header{"Content-Encoding": {"gzip"}}, ContentLength: int64(len(compressed)),
+			Body: ioutil.NopCloser(bytes.NewReader(compressed))}
+		if err = decodeBody(resp); err != nil || !resp.Uncompressed || resp.ContentLength != -1 {
+			t.Fatal("decode:", err)
+		}
+		if b, err := ioutil.ReadAll(resp.Body); err != nil || string(b) != data {
+			t.Error("decompressed:", err)
+		}
+	}
+	// smaller than MinSize, of known and unknown size
+	for _, r := range []io.Reader{strings.NewReader("small"), ioutil.NopCloser(strings.NewReader("small"))} {
+		content, encoding, err := c.compress("text/plain", r)
+		if b, _ := ioutil.ReadAll(content); err != nil || encoding != "" || string(b) != "small" {
+			t.Error("small file:", encoding, err)
+		}
+	}
+	if _, _, err := (&Compression{Encoding: "br"}).compress("text/plain", strings.NewReader(data)); err == nil {
+		t.Error("unknown encoding should fail")
+	}
+
+	// registered encodings are accepted and decompressed
+	RegisterCompressor("upper", Compressor{
+		NewWriter: func(w io.Writer, level int) (io.WriteCloser, error) { return nil, nil },
+		NewReader: func(r io.Reader) (io.ReadCloser, error) {
+			b, err := ioutil.ReadAll(r)
+			return ioutil.NopCloser(strings.NewReader(strings.ToUpper(string(b)))), err
+		},
+	})
+	defer func() {
+		compressorsMu.Lock()
+		delete(compressors, "upper")
+		compressorsMu.Unlock()
+	}()
+	h := http.Header{}
+	acceptEncoding(h)
+	if h.Get("Accept-Encoding") != "gzip, upper" {
+		t.Error("accept encoding:", h.Get("Accept-Encoding"))
+	}
+	resp := &http.Response{Header: http.Header{"Content-Encoding": {"Upper"}}, Body: ioutil.NopCloser(strings.NewReader("weedo"))}
+	decodeBody(resp)
+	if b, _ := ioutil.ReadAll(resp.Body); string(b) != "WEEDO" {
+		t.Error("registered encoding:", string(b))
+	}
+}
+
+func TestCompressedUpload(t *testing.T) {
+	data := strings.Repeat("compress me ", 100)
+	opt := &UploadOption{Verify: true, Compression: &Compression{Encoding: "gzip"}}
+	fid, _, err := client.AssignUploadWithOption("compressed.txt", "text/plain", strings.NewReader(data), opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Delete(fid, 1)
+	body, _, err := client.Get(fid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+	if b, err := ioutil.ReadAll(body); err != nil || string(b) != data {
+		t.Error("decompressed:", err)
+	}
+}
+
+func TestRangeReader(t *testing.T) {
+	data := make([]byte, 3*readAhead+100)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	requests := 0
+	ranges := true
+	etag := `"v1"`
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Etag", etag)
+		content := data
+		if r.URL.Path == "/empty" {
+			content = nil
+		}
+		if !ranges {
+			r.Header.Del("Range")
+		}
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+	}))
+	defer s.Close()
+
+	for _, ranges = range []bool{true, false} {
+		body, err := NewVolume(s.URL, s.URL).ReadRange("3,01", 10, 20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := ioutil.ReadAll(body)
+		body.Close()
+		if !bytes.Equal(b, data[10:30]) {
+			t.Error("read range:", ranges, b)
+		}
+		if _, err = NewVolume(s.URL, s.URL).ReadRange("3,01", int64(len(data)), -1); err == nil {
+			t.Error("range out of file should fail", ranges)
+		}
+
+		requests = 0
+		r, err := NewFiler(s.URL).Open("/docs/a.bin")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Size() != int64(len(data)) || r.Info().Name != "a.bin" {
+			t.Fatal("size:", r.Size(), r.Info())
+		}
+		// small reads are served by read ahead
+		p := make([]byte, 100)
+		for off := int64(0); off < 10*100; off += 100 {
+			if n, err := r.ReadAt(p, off); n != len(p) || err != nil || !bytes.Equal(p, data[off:off+100]) {
+				t.Fatal("read at", off, n, err)
+			}
+		}
+		if requests != 1 {
+			t.Error("requests of small reads:", requests)
+		}
+		if n, err := r.ReadAt(p, int64(len(data)-50)); n != 50 || err != io.EOF || !bytes.Equal(p[:n], data[len(data)-50:]) {
+			t.Error("read at the end:", n, err)
+		}
+		big := make([]byte, readAhead+1)
+		if n, err := r.ReadAt(big, readAhead); n != len(big) || err != nil || !bytes.Equal(big, data[readAhead:2*readAhead+1]) {
+			t.Error("big read:", n, err)
+		}
+
+		if pos, err := r.Seek(-30, io.SeekEnd); err != nil || pos != int64(len(data)-30) {
+			t.Fatal("seek:", pos, err)
+		}
+		if b, err := ioutil.ReadAll(r); err != nil || !bytes.Equal(b, data[len(data)-30:]) {
+			t.Error("read after seek:", len(b), err)
+		}
+		r.Seek(0, io.SeekStart)
+		if b, err := ioutil.ReadAll(r); err != nil || !bytes.Equal(b, data) {
+			t.Error("read all:", len(b), err)
+		}
+		if _, err = r.Seek(-1, io.SeekStart); err == nil {
+			t.Error("negative position should fail")
+		}
+
+		// replaced after opened
+		etag = `"v2"`
+		if _, err := r.ReadAt(p, 2*readAhead); err != ErrFileChanged {
+			t.Error("changed file:", ranges, err)
+		}
+		etag = `"v1"`
+	}
+	ranges = true
+	r, err := NewFiler(s.URL).Open("/empty")
+	if err != nil || r.Size() != 0 {
+		t.Fatal("empty file:", err)
+	}
+	if n, err := r.Read(make([]byte, 10)); n != 0 || err != io.EOF {
+		t.Error("read empty file:", n, err)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	data := strings.Repeat("0123456789", 10)
+	fid, _, err := client.AssignUpload("range.txt", "text/plain", strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Delete(fid, 1)
+	r, err := client.Open(fid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := make([]byte, 5)
+	if n, err := r.ReadAt(p, 42); err != nil || string(p[:n]) != "23456" || r.Size() != int64(len(data)) {
+		t.Error("read at:", string(p[:n]), err, r.Size())
+	}
+}
+
+func TestVolumeTTLCache(t *testing.T) {
+	statuses := 0
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/status" {
+			statuses++
+			fmt.Fprint(w, `{"Volumes":[{"Id":3,"Ttl":{"Count":3,"Unit":3}}]}`)
+			return
+		}
+		fmt.Fprint(w, "hello")
+	}))
+	defer s.Close()
+	vol := NewVolume(s.URL, s.URL)
+	for i := 0; i < 3; i++ {
+		for _, fid := range []string{"3,01637037d6", "4,01637037d6"} {
+			info, err := vol.Head(fid)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := map[bool]string{true: "3d", false: ""}[fid[0] == '3']; info.Ttl.String() != want {
+				t.Error("ttl of", fid, info.Ttl)
+			}
+		}
+	}
+	// volume 4 unknown to the server is not checked again at once
+	if statuses != 1 {
+		t.Error("status requests:", statuses)
+	}
+	if status, err := vol.VolumeStatus(); err != nil || len(status.Volumes) != 1 || vol.Status() != nil {
+		t.Error("status:", status, err)
+	}
+}
+
+func TestConditions(t *testing.T) {
+	modified := time.Date(2026, 10, 19, 8, 0, 0, 0, time.UTC)
+	gets := 0
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/status" {
+			fmt.Fprint(w, `{"Volumes":[]}`)
+			return
+		}
+		if r.Method == "GET" {
+			gets++
+		}
+		w.Header().Set("Etag", `"abc"`)
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Seaweed-Owner", "weedo")
+		http.ServeContent(w, r, "", modified, strings.NewReader("hello"))
+	}))
+	defer s.Close()
+	vol := NewVolume(s.URL, s.URL)
+
+	info, err := vol.Head("3,01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size != 5 || info.ETag != "abc" || !info.LastModified.Equal(modified) ||
+		info.MimeType != "text/plain" || info.Pairs["Owner"] != "weedo" || gets != 0 {
+		t.Error("head:", info, gets)
+	}
+
+	for _, cond := range []*Conditions{
+		{IfNoneMatch: "abc"},
+		{IfNoneMatch: `"abc"`},
+		{IfNoneMatch: "*"},
+		{IfModifiedSince: modified},
+		{IfModifiedSince: modified.Add(time.Hour)},
+	} {
+		if _, _, err := vol.GetIf("3,01", cond); !IsNotModified(err) {
+			t.Error("not modified:", cond, err)
+		}
+	}
+	for _, cond := range []*Conditions{
+		nil,
+		{IfNoneMatch: "old"},
+		{IfModifiedSince: modified.Add(-time.Hour)},
+	} {
+		body, info, err := vol.GetIf("3,01", cond)
+		if err != nil {
+			t.Fatal(cond, err)
+		}
+		b, _ := ioutil.ReadAll(body)
+		body.Close()
+		if string(b) != "hello" || info.ETag != "abc" {
+			t.Error("modified:", cond, string(b))
+		}
+	}
+	if IsNotModified(&HttpError{StatusCode: http.StatusNotFound}) || IsNotModified(nil) {
+		t.Error("not modified")
+	}
+}
+
+func TestHead(t *testing.T) {
+	fid, _, err := client.AssignUploadWithOption("head.txt", "text/plain", strings.NewReader("hello"), &UploadOption{Pairs: map[string]string{"Owner": "weedo"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Delete(fid, 1)
+	info, err := client.Head(fid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size != 5 || info.ETag == "" || info.Pairs["Owner"] != "weedo" {
+		t.Error("head:", info)
+	}
+	if _, _, err = client.GetIf(fid, &Conditions{IfNoneMatch: info.ETag}); !IsNotModified(err) {
+		t.Error("not modified:", err)
+	}
+	body, _, err := client.GetIf(fid, &Conditions{IfNoneMatch: "stale"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body.Close()
+}
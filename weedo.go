@@ -18,6 +18,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 var defaultClient *Client
@@ -27,14 +28,29 @@ func init() {
 	defaultClient = NewClient("localhost:9333")
 }
 
-type Fid struct {
-	Id, Key, Cookie uint64
-}
+// File id of seaweedfs, see timekey.Fid
+type Fid = timekey.Fid
 
+// Client is safe for concurrent use, but the Set* methods must be called
+// before it's shared
 type Client struct {
 	master  *Master
-	volumes map[uint64]*Volume
+	mu      sync.RWMutex // guards volumes and filers
+	volumes map[uint32]*Volume
 	filers  map[string]*Filer
+	// jwt settings for secured volume servers
+	writeKey, readKey string
+	jwtExpires        int
+	masterJwt         bool
+	// secret to sign cookies of timekey fids
+	tkSecret []byte
+	// generator of timekey fids, the default one of timekey if nil
+	tkGen *timekey.Generator
+	// spool of readers with unknown size
+	spoolMemory int64
+	spoolDir    string
+	// check reads of Get against etags of volume servers
+	verifyReads bool
 }
 
 func NewClient(masterUrl string, filerUrls ...string) *Client {
@@ -44,9 +60,10 @@ func NewClient(masterUrl string, filerUrls ...string) *Client {
 		filers[filer.Url] = filer
 	}
 	return &Client{
-		master:  NewMaster(masterUrl),
-		volumes: make(map[uint64]*Volume),
-		filers:  filers,
+		master:      NewMaster(masterUrl),
+		volumes:     make(map[uint32]*Volume),
+		filers:      filers,
+		spoolMemory: DefaultSpoolMemory,
 	}
 }
 
@@ -58,28 +75,106 @@ func (c *Client) Volume(volumeId, collection string) (*Volume, error) {
 	vid, _ := strconv.ParseUint(volumeId, 10, 32)
 	if vid == 0 {
 		fid, _ := ParseFid(volumeId)
-		vid = fid.Id
+		vid = uint64(fid.Id)
 	}
 
 	if vid == 0 {
 		return nil, errors.New("id malformed")
 	}
 
-	if v, ok := c.volumes[vid]; ok {
+	return c.volume(uint32(vid), collection)
+}
+
+func (c *Client) volume(vid uint32, collection string) (*Volume, error) {
+	c.mu.RLock()
+	v, ok := c.volumes[vid]
+	c.mu.RUnlock()
+	if ok {
 		return v, nil
 	}
-	vol, err := c.Master().lookup(volumeId, collection)
+	vol, err := c.Master().lookup(strconv.FormatUint(uint64(vid), 10), collection)
 	if err != nil {
 		return nil, err
 	}
 
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// keep the one looked up by others at the same time
+	if v, ok := c.volumes[vid]; ok {
+		return v, nil
+	}
 	c.volumes[vid] = vol
 
 	return vol, nil
 }
 
+// Sign jwt for volume servers locally, writeKey and readKey must be the same as
+// jwt.signing.key and jwt.signing.read.key in security.toml of seaweedfs.
+// An empty key disables the kind of jwt, expiresAfterSec <= 0 means never expire
+func (c *Client) SetJwtSigningKey(writeKey, readKey string, expiresAfterSec int) {
+	c.writeKey = writeKey
+	c.readKey = readKey
+	c.jwtExpires = expiresAfterSec
+}
+
+// Look up jwt signed by master from /dir/lookup for deletes and reads.
+// The auth returned by /dir/assign is always used for uploads.
+// Keys set by SetJwtSigningKey take precedence
+func (c *Client) UseMasterJwt(enable bool) {
+	c.masterJwt = enable
+}
+
+// jwt of fid for writing or reading, empty if jwt is not enabled
+func (c *Client) jwt(fid string, write bool) (Jwt, error) {
+	key := c.readKey
+	if write {
+		key = c.writeKey
+	}
+	if key != "" {
+		return GenJwt(key, c.jwtExpires, fid), nil
+	}
+	if c.masterJwt {
+		return c.Master().LookupJwt(fid, !write)
+	}
+	return "", nil
+}
+
+// Sign cookies of fids uploaded by AssignUploadTK with secret, so that a
+// guessed fid is valid one time in 16384 only, see
+// timekey.Fid.InsertSignedCookie. nil to disable
+func (c *Client) SetTimekeySecret(secret []byte) {
+	c.tkSecret = secret
+}
+
+// Generate keys of fids uploaded by AssignUploadTK with node id in
+// [0, timekey.MaxNode], so that they never collide with keys of other
+// processes with their own node ids. A random node id is used by default.
+// Must be called before the client is shared
+func (c *Client) SetTimekeyNode(node int) error {
+	g, err := timekey.NewGenerator(node)
+	if err != nil {
+		return err
+	}
+	c.tkGen = g
+	return nil
+}
+
+// Verify reads of Get against etags returned by volume servers, see Get
+func (c *Client) VerifyReads(enable bool) {
+	c.verifyReads = enable
+}
+
+// Readers of unknown size are spooled in memory up to maxMemory bytes, and
+// in temp files under dir for the rest, the default dir is os.TempDir()
+func (c *Client) SetSpool(maxMemory int64, dir string) {
+	c.spoolMemory = maxMemory
+	c.spoolDir = dir
+}
+
 func (c *Client) Filer(url string) *Filer {
 	filer := NewFiler(url)
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	if v, ok := c.filers[filer.Url]; ok {
 		return v
 	}
@@ -88,28 +183,13 @@ func (c *Client) Filer(url string) *Filer {
 	return filer
 }
 
+// Parse fid strictly, see timekey.ParseFid
 func ParseFid(s string) (fid Fid, err error) {
-	a := strings.Split(s, ",")
-	if len(a) != 2 || len(a[1]) <= 8 {
-		return fid, errors.New("Fid format invalid")
-	}
-	if fid.Id, err = strconv.ParseUint(a[0], 10, 32); err != nil {
-		return
-	}
-	index := len(a[1]) - 8
-	if fid.Key, err = strconv.ParseUint(a[1][:index], 16, 64); err != nil {
-		return
-	}
-	if fid.Cookie, err = strconv.ParseUint(a[1][index:], 16, 32); err != nil {
+	f, err := timekey.ParseFid(s)
+	if err != nil {
 		return
 	}
-
-	return
-}
-
-// Fid in string form
-func (f *Fid) String() string {
-	return fmt.Sprintf("%d,%x%08x", f.Id, f.Key, f.Cookie)
+	return *f, nil
 }
 
 // First, contact with master server and assign a fid, then upload to volume server
@@ -137,46 +217,224 @@ func (c *Client) GetUrl(fid string) (publicUrl, url string, err error) {
 }
 
 func (c *Client) AssignUpload(filename, mimeType string, file io.Reader) (fid string, size int64, err error) {
+	return c.AssignUploadWithOption(filename, mimeType, file, nil)
+}
 
-	fid, err = c.Master().Assign()
+// AssignUpload with optional parameters, Collection, Replication and Ttl
+// apply to assign, files larger than MaxChunkSize are uploaded in chunks,
+// Version is ignored and Jwt is filled by the client
+func (c *Client) AssignUploadWithOption(filename, mimeType string, file io.Reader, opt *UploadOption) (fid string, size int64, err error) {
+	if opt == nil {
+		opt = new(UploadOption)
+	}
+	if opt.MaxChunkSize > 0 {
+		cm, rest, err := c.uploadChunks(filename, mimeType, file, opt)
+		if err != nil {
+			return "", 0, err
+		}
+		if cm != nil {
+			fid, _, err = c.assignUploadManifest(cm, opt)
+			return fid, cm.Size, err
+		}
+		file = rest
+	}
+	assign, err := c.Master().assign(opt.assignOption())
 	if err != nil {
 		return
 	}
+	fid = assign.Fid
 
 	vol, err := c.Volume(fid, "")
 	if err != nil {
 		return
 	}
-	size, err = vol.Upload(fid, filename, mimeType, file)
+	opt, err = c.uploadOption(fid, assign.Auth, opt)
+	if err != nil {
+		return
+	}
+	size, err = vol.UploadWithOption(fid, filename, mimeType, file, opt)
 
 	return
 }
 
+// upload chunk manifest in place of the file
+func (c *Client) assignUploadManifest(cm *ChunkManifest, opt *UploadOption) (fid string, size int64, err error) {
+	data, err := json.Marshal(cm)
+	if err != nil {
+		return
+	}
+	o := *opt
+	o.MaxChunkSize = 0
+	o.manifest = true
+	fid, size, err = c.AssignUploadWithOption(cm.Name, "application/json", bytes.NewReader(data), &o)
+	if err != nil {
+		c.deleteChunks(cm)
+	}
+	return
+}
+
+// copy of opt with jwt of fid, auth is the jwt returned by master on assigning
+func (c *Client) uploadOption(fid string, auth Jwt, opt *UploadOption) (*UploadOption, error) {
+	o := UploadOption{}
+	if opt != nil {
+		o = *opt
+	}
+	o.Version = 0
+	if auth != "" && c.writeKey == "" {
+		o.Jwt = auth
+		return &o, nil
+	}
+	jwt, err := c.jwt(fid, true)
+	if err != nil {
+		return nil, err
+	}
+	o.Jwt = jwt
+	return &o, nil
+}
+
+// Download file from volume server, the caller must close the returned body.
+// With VerifyReads, reading the body to the end returns a *ChecksumError
+// instead of io.EOF if the content doesn't match the etag
+func (c *Client) Get(fid string) (body io.ReadCloser, info *FileInfo, err error) {
+	return c.GetIf(fid, nil)
+}
+
+// Get unless the file matches cond, for revalidating a copy of it. An error
+// satisfying IsNotModified is returned if it matches
+func (c *Client) GetIf(fid string, cond *Conditions) (body io.ReadCloser, info *FileInfo, err error) {
+	vol, err := c.Volume(fid, "")
+	if err != nil {
+		return
+	}
+	jwt, err := c.jwt(fid, false)
+	if err != nil {
+		return
+	}
+	resp, err := vol.get(fid, jwt, cond, c.verifyReads)
+	if err != nil {
+		return
+	}
+	return resp.Body, vol.fileInfo(fid, resp), nil
+}
+
+// Size, etag, last modified time, mime type and metadata pairs of file,
+// without downloading it
+func (c *Client) Head(fid string) (*FileInfo, error) {
+	vol, err := c.Volume(fid, "")
+	if err != nil {
+		return nil, err
+	}
+	jwt, err := c.jwt(fid, false)
+	if err != nil {
+		return nil, err
+	}
+	return vol.Head(fid, jwt)
+}
+
+// AssignUpload with Verify, returning md5 and sha256 of the whole file
+func (c *Client) AssignUploadSum(filename, mimeType string, file io.Reader, opt *UploadOption) (fid string, size int64, sum *Checksum, err error) {
+	o := UploadOption{}
+	if opt != nil {
+		o = *opt
+	}
+	o.Verify = true
+	h := newHasher()
+	fid, size, err = c.AssignUploadWithOption(filename, mimeType, io.TeeReader(file, h), &o)
+	if err != nil {
+		return
+	}
+	return fid, size, h.sum(), nil
+}
+
 // uinsg time/cookie as Fid
+// The size is saved in cookie, if fileSize < 0 it's read from r for files
+// and in-memory readers, or r is spooled to get it, see SetSpool
 func (c *Client) AssignUploadTK(filename string, r io.Reader, fileSize int) (fid string, err error) {
-	fid, err = c.Master().Assign()
+	return c.AssignUploadTKWithOption(filename, r, fileSize, nil)
+}
+
+// AssignUploadTK with optional parameters as AssignUploadWithOption.
+// Chunks of files larger than MaxChunkSize are not timekey fids, but the
+// chunk manifest is, with the size of the whole file in cookie
+func (c *Client) AssignUploadTKWithOption(filename string, r io.Reader, fileSize int, opt *UploadOption) (fid string, err error) {
+	if opt == nil {
+		opt = new(UploadOption)
+	}
+	mimeType := mime.TypeByExtension(path.Ext(filename))
+	if opt.MaxChunkSize > 0 && (fileSize < 0 || int64(fileSize) > opt.MaxChunkSize) {
+		cm, rest, err := c.uploadChunks(filename, mimeType, r, opt)
+		if err != nil {
+			return "", err
+		}
+		if cm != nil {
+			data, err := json.Marshal(cm)
+			if err != nil {
+				return "", err
+			}
+			o := *opt
+			o.manifest = true
+			fid, err = c.assignUploadTK(filename, mimeType, bytes.NewReader(data), int(cm.Size), &o)
+			if err != nil {
+				c.deleteChunks(cm)
+			}
+			return fid, err
+		}
+		r, fileSize = rest, -1
+	}
+	if fileSize < 0 {
+		sp, err := spool(r, c.spoolMemory, c.spoolDir)
+		if err != nil {
+			return "", err
+		}
+		defer sp.Close()
+		r, fileSize = sp, int(sp.size)
+	}
+	return c.assignUploadTK(filename, mimeType, r, fileSize, opt)
+}
+
+// upload r as a timekey fid with fileSize and mimeType in cookie
+func (c *Client) assignUploadTK(filename, mimeType string, r io.Reader, fileSize int, opt *UploadOption) (fid string, err error) {
+	assign, err := c.Master().assign(opt.assignOption())
 	if err != nil {
 		return
 	}
-	tkfid, err := timekey.ParseFid(fid)
+	tkfid, err := timekey.ParseFid(assign.Fid)
 	if err != nil {
 		return
 	}
 	// insert self defined key using timekey
-	tkfid.InsertTimeKey()
-	tkfid.InsertCookie(fileSize, mime.TypeByExtension(path.Ext(filename)))
+	tkfid.InsertTimeKey(c.tkGen)
+	if c.tkSecret != nil {
+		tkfid.InsertSignedCookie(c.tkSecret, fileSize, mimeType)
+	} else {
+		tkfid.InsertCookie(fileSize, mimeType)
+	}
 	fid = tkfid.String()
 	// find vold
-	vol, err := c.Volume(fid, "")
+	vol, err := c.volume(tkfid.Id, "")
+	if err != nil {
+		return fid, err
+	}
+	// jwt from master is bound to the assigned fid, not the timekey one
+	opt, err = c.uploadOption(fid, "", opt)
 	if err != nil {
 		return fid, err
 	}
-	_, err = vol.Upload(fid, filename, tkfid.MimeType(), r)
+	mimeType = tkfid.MimeType()
+	if opt.manifest {
+		mimeType = "application/json"
+	}
+	_, err = vol.UploadWithOption(fid, filename, mimeType, r, opt)
 	return
 }
 
 // Assign Fid using timekey.Fid
 func (c *Client) UploadFileTK(fullPath string) (fid string, err error) {
+	return c.UploadFileTKWithOption(fullPath, nil)
+}
+
+// UploadFileTK with optional parameters as AssignUploadTKWithOption
+func (c *Client) UploadFileTKWithOption(fullPath string, opt *UploadOption) (fid string, err error) {
 	// get filename
 	filename := filepath.Base(fullPath)
 	info, err := os.Stat(fullPath)
@@ -189,7 +447,7 @@ func (c *Client) UploadFileTK(fullPath string) (fid string, err error) {
 	}
 	defer r.Close()
 	// upload
-	return c.AssignUploadTK(filename, r, int(info.Size()))
+	return c.AssignUploadTKWithOption(filename, r, int(info.Size()), opt)
 }
 
 func (c *Client) Delete(fid string, count int) (err error) {
@@ -197,7 +455,97 @@ func (c *Client) Delete(fid string, count int) (err error) {
 	if err != nil {
 		return
 	}
-	return vol.Delete(fid, count)
+	jwt, err := c.jwt(fid, true)
+	if err != nil {
+		return
+	}
+	return vol.Delete(fid, count, jwt)
+}
+
+const (
+	batchDeleteSize   = 1000 // fids per batch delete request
+	deleteParallelism = 16   // concurrent DELETEs per volume server
+)
+
+// Delete many fids grouped by volume server, using batch delete of volume
+// servers if available or parallel DELETEs otherwise. Results are in the
+// same order as fids, failures are reported by DeleteResult.Error
+func (c *Client) DeleteMany(fids []string) []*DeleteResult {
+	results := make([]*DeleteResult, len(fids))
+	// fids indexes grouped by volume server url
+	groups := make(map[string][]int)
+	vols := make(map[string]*Volume)
+	for i, fid := range fids {
+		vol, err := c.Volume(fid, "")
+		if err != nil {
+			results[i] = &DeleteResult{Fid: fid, Error: err.Error()}
+			continue
+		}
+		groups[vol.Url] = append(groups[vol.Url], i)
+		vols[vol.Url] = vol
+	}
+
+	var wg sync.WaitGroup
+	for url, idx := range groups {
+		wg.Add(1)
+		go func(vol *Volume, idx []int) {
+			defer wg.Done()
+			c.deleteFromVolume(vol, fids, idx, results)
+		}(vols[url], idx)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// delete fids[idx] from vol, results are saved in results[idx]
+func (c *Client) deleteFromVolume(vol *Volume, fids []string, idx []int, results []*DeleteResult) {
+	// batch delete can't carry jwt of each fid
+	batch := c.writeKey == "" && !c.masterJwt
+	for len(idx) > 0 && batch {
+		n := batchDeleteSize
+		if n > len(idx) {
+			n = len(idx)
+		}
+		chunk := make([]string, n)
+		for i := range chunk {
+			chunk[i] = fids[idx[i]]
+		}
+		rets, err := vol.BatchDelete(chunk)
+		if err == ErrBatchDeleteUnsupported {
+			break
+		}
+		for i := range chunk {
+			if err != nil {
+				results[idx[i]] = &DeleteResult{Fid: chunk[i], Error: err.Error()}
+			} else {
+				results[idx[i]] = rets[i]
+			}
+		}
+		idx = idx[n:]
+	}
+
+	queue := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < deleteParallelism && i < len(idx); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				jwt, err := c.jwt(fids[i], true)
+				if err != nil {
+					results[i] = &DeleteResult{Fid: fids[i], Error: err.Error()}
+					continue
+				}
+				results[i] = vol.deleteOne(fids[i], jwt)
+			}
+		}()
+	}
+	for _, i := range idx {
+		queue <- i
+	}
+	close(queue)
+	wg.Wait()
 }
 
 var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
@@ -206,7 +554,7 @@ func escapeQuotes(s string) string {
 	return quoteEscaper.Replace(s)
 }
 
-func createFormFile(writer *multipart.Writer, fieldname, filename, mime string) (io.Writer, error) {
+func createFormFile(writer *multipart.Writer, fieldname, filename, mime, encoding string) (io.Writer, error) {
 	h := make(textproto.MIMEHeader)
 	h.Set("Content-Disposition",
 		fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
@@ -215,31 +563,32 @@ func createFormFile(writer *multipart.Writer, fieldname, filename, mime string)
 		mime = "application/octet-stream"
 	}
 	h.Set("Content-Type", mime)
+	if encoding != "" {
+		h.Set("Content-Encoding", encoding)
+	}
 	return writer.CreatePart(h)
 }
 
-func makeFormData(filename, mimeType string, content io.Reader) (formData io.Reader, contentType string, err error) {
-	buf := new(bytes.Buffer)
-	writer := multipart.NewWriter(buf)
-
-	part, err := createFormFile(writer, "file", filename, mimeType)
-	//log.Println(filename, mimeType)
-	if err != nil {
-		log.Println(err)
-		return
-	}
-	_, err = io.Copy(part, content)
-	if err != nil {
-		log.Println(err)
-		return
-	}
-
-	formData = buf
-	contentType = writer.FormDataContentType()
-	//log.Println(contentType)
-	writer.Close()
-
-	return
+// multipart form data streamed from content while being read, encoding is
+// the content encoding of content if it's compressed
+func makeFormData(filename, mimeType, encoding string, content io.Reader) (formData io.Reader, contentType string, err error) {
+	pr, pw := io.Pipe()
+	writer := multipart.NewWriter(pw)
+	go func() {
+		part, err := createFormFile(writer, "file", filename, mimeType, encoding)
+		if err == nil {
+			_, err = io.Copy(part, content)
+		}
+		if err == nil {
+			err = writer.Close()
+		}
+		if err != nil {
+			log.Println(err)
+		}
+		pw.CloseWithError(err)
+	}()
+
+	return pr, writer.FormDataContentType(), nil
 }
 
 type uploadResp struct {
@@ -247,11 +596,24 @@ type uploadResp struct {
 	FileName string
 	FileUrl  string
 	Size     int64
+	ETag     string
 	Error    string
 }
 
-func upload(url string, contentType string, formData io.Reader) (r *uploadResp, err error) {
-	resp, err := http.Post(url, contentType, formData)
+func upload(url string, contentType string, formData io.Reader, header http.Header) (r *uploadResp, err error) {
+	request, err := http.NewRequest("POST", url, formData)
+	if err != nil {
+		// stop the writer of form data
+		if c, ok := formData.(io.Closer); ok {
+			c.Close()
+		}
+		return
+	}
+	for k, v := range header {
+		request.Header[k] = v
+	}
+	request.Header.Set("Content-Type", contentType)
+	resp, err := http.DefaultClient.Do(request)
 	if err != nil {
 		log.Println(err)
 		return
@@ -273,15 +635,78 @@ func upload(url string, contentType string, formData io.Reader) (r *uploadResp,
 	return
 }
 
-func del(url string) error {
-	client := http.Client{}
+func del(url string, header http.Header) error {
 	request, err := http.NewRequest("DELETE", url, nil)
 	if err != nil {
 		return err
 	}
-	resp, err := client.Do(request)
+	for k, v := range header {
+		request.Header[k] = v
+	}
+	resp, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return err
+	}
 	resp.Body.Close()
-	return err
+	return nil
+}
+
+// GET url, returns an error unless the status is 2xx
+func get(url string, header http.Header) (*http.Response, error) {
+	return send("GET", url, header)
+}
+
+// HEAD url, returns an error unless the status is 2xx
+func head(url string, header http.Header) (*http.Response, error) {
+	resp, err := send("HEAD", url, header)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return resp, nil
+}
+
+// request url without body, returns an error unless the status is 2xx
+func send(method, url string, header http.Header) (*http.Response, error) {
+	request, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		request.Header[k] = v
+	}
+	resp, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		resp.Body.Close()
+		return nil, &HttpError{Url: url, StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	return resp, nil
+}
+
+// Error of a request answered with a status other than 2xx
+type HttpError struct {
+	Url        string
+	StatusCode int
+	Status     string
+}
+
+func (e *HttpError) Error() string {
+	return e.Url + ": " + e.Status
+}
+
+// Is err caused by a file not found
+func IsNotFound(err error) bool {
+	e, ok := err.(*HttpError)
+	return ok && e.StatusCode == http.StatusNotFound
+}
+
+// Is err caused by a conditional read of a file not modified
+func IsNotModified(err error) bool {
+	e, ok := err.(*HttpError)
+	return ok && e.StatusCode == http.StatusNotModified
 }
 
 func decodeJson(r io.Reader, v interface{}) error {